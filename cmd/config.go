@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"ocp/sample/planets/internal/config"
+	logutil "ocp/sample/planets/internal/util/log"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the named configuration profiles.",
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles in the config file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var active string
+
+		file, err := config.LoadFile()
+
+		if err == nil {
+			active, err = config.ActiveProfileName()
+		}
+
+		if err == nil {
+			for _, name := range file.Names() {
+				marker := " "
+				if name == active {
+					marker = "*"
+				}
+
+				fmt.Printf("%s %s\n", marker, name)
+			}
+
+			if len(file.Profiles) == 0 {
+				path, _ := config.ConfigFilePath()
+				fmt.Printf("No profiles found in %s\n", path)
+			}
+		}
+
+		return err
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [profile]",
+	Short: "Show a profile, or the settings in effect when no profile is named. Secrets are redacted.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return showProfile(args[0])
+		}
+
+		return showSettings()
+	},
+}
+
+var configUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Make a profile the one used when --profile isn't given.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := config.LoadFile()
+
+		if _, ok := file.Profiles[args[0]]; err == nil && !ok {
+			err = &config.Error{Message: fmt.Sprintf("Profile %s is not defined in the config file.", args[0])}
+			logutil.LogError(err)
+		}
+
+		if err == nil {
+			file.CurrentProfile = args[0]
+			err = config.SaveFile(file)
+		}
+
+		if err == nil {
+			logutil.Log(logutil.INFO_LEVEL, "Now using profile", "profile", args[0])
+		}
+
+		return err
+	},
+}
+
+// Prints a single profile from the config file with its secret redacted.
+func showProfile(name string) error {
+	file, err := config.LoadFile()
+
+	profile, ok := file.Profiles[name]
+
+	if err == nil && !ok {
+		err = &config.Error{Message: fmt.Sprintf("Profile %s is not defined in the config file.", name)}
+		logutil.LogError(err)
+	}
+
+	if err == nil {
+		profile = profile.Redacted()
+		fmt.Printf("Profile: %s\n", name)
+		fmt.Printf("Base url: %s\n", profile.BaseUrl)
+		fmt.Printf("Tenant id: %s\n", profile.TenantId)
+		fmt.Printf("Confidential client id: %s\n", profile.ConfClientId)
+		fmt.Printf("Client secret: %s\n", profile.ClientSecret)
+		fmt.Printf("Sample data path: %s\n", profile.SampleDataPath)
+		fmt.Printf("Grant type: %s\n", profile.GrantType)
+		fmt.Printf("Username: %s\n", profile.Username)
+		fmt.Printf("Password: %s\n", profile.Password)
+		fmt.Printf("Refresh token: %s\n", profile.RefreshToken)
+	}
+
+	return err
+}
+
+// Prints every setting in effect and where its value came from.
+func showSettings() error {
+	var settings []config.Setting
+
+	active, err := config.ActiveProfileName()
+
+	if err == nil {
+		settings, err = config.Settings()
+	}
+
+	if err == nil {
+		fmt.Printf("Profile: %s\n", active)
+
+		for _, setting := range settings {
+			fmt.Printf("%s: %s (%s)\n", setting.Key, setting.Value, setting.Source)
+		}
+	}
+
+	return err
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configUseCmd)
+
+	PlanetsCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"errors"
+	"ocp/sample/planets/internal/cms"
+	"ocp/sample/planets/internal/config"
+	authutil "ocp/sample/planets/internal/util/auth"
+	sdk "ocp/sample/planets/pkg/cms"
+)
+
+// Exit codes returned by the cli so scripts and CI pipelines can tell failures apart
+const (
+	EXIT_OK              = 0
+	EXIT_ERROR           = 1
+	EXIT_CONFIG_ERROR    = 2
+	EXIT_AUTH_ERROR      = 3
+	EXIT_PARTIAL_FAILURE = 4
+	EXIT_TOTAL_FAILURE   = 5
+	EXIT_NOT_FOUND       = 6
+)
+
+// Maps an error returned by a command to the cli exit code. A batch of more than one item exits with the
+// partial or total failure code whatever its items failed with; a single item exits as its own error would.
+func exitCode(err error) int {
+	var configErr *config.Error
+	var missingErr *authutil.MissingCredentialError
+	var authErr *sdk.AuthError
+	var batchErr *cms.BatchError
+	var notFoundErr *sdk.NotFoundError
+
+	if errors.As(err, &batchErr) && batchErr.Total > 1 {
+		if batchErr.AllFailed() {
+			return EXIT_TOTAL_FAILURE
+		}
+
+		return EXIT_PARTIAL_FAILURE
+	}
+
+	switch {
+	case err == nil:
+		return EXIT_OK
+	case errors.As(err, &configErr), errors.As(err, &missingErr):
+		return EXIT_CONFIG_ERROR
+	case errors.As(err, &authErr):
+		return EXIT_AUTH_ERROR
+	case errors.As(err, &notFoundErr):
+		return EXIT_NOT_FOUND
+	case errors.As(err, &batchErr):
+		return EXIT_TOTAL_FAILURE
+	default:
+		return EXIT_ERROR
+	}
+}
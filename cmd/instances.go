@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"errors"
+	"ocp/sample/planets/internal/cms"
+	ioutil "ocp/sample/planets/internal/util/io"
+	logutil "ocp/sample/planets/internal/util/log"
+	sdk "ocp/sample/planets/pkg/cms"
+
+	"github.com/spf13/cobra"
+)
+
+var instanceCategory string
+var instanceType string
+var instanceName string
+var instanceProperties string
+var instanceFile string
+var deleteAllInstances bool
+
+var instancesCmd = &cobra.Command{
+	Use:   "instances",
+	Short: "Manage CMS instances of any category and type.",
+}
+
+var instancesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print CMS instance info for a category and type.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		listOpts := sdk.ListOptions{}
+
+		if err == nil {
+			listOpts, err = listOptions()
+		}
+
+		if err == nil {
+			err = client.InstanceInfo(cmd.Context(), instanceCategory, instanceType, listOpts)
+		}
+
+		return err
+	},
+}
+
+var instancesGetCmd = &cobra.Command{
+	Use:   "get [id]",
+	Short: "Print a single CMS instance, by id or by --name.",
+	Args:  idArgs("Provide either an instance id or --name", func() bool { return len(getName) > 0 }),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var client *cms.Client
+
+		client, err = newClient()
+
+		if err == nil && len(getName) > 0 {
+			err = client.InstanceByNameInfo(cmd.Context(), instanceCategory, instanceType, getName)
+		} else if err == nil {
+			err = client.InstanceByIdInfo(cmd.Context(), instanceCategory, instanceType, args[0])
+		}
+
+		return
+	},
+}
+
+var instancesCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create CMS instances from --name/--properties or a JSON --file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var client *cms.Client
+
+		payload, err := instancePayload()
+
+		if err == nil {
+			client, err = newClient()
+		}
+
+		if err == nil {
+			_, err = client.CreateInstances(cmd.Context(), instanceCategory, instanceType, payload)
+		}
+
+		return err
+	},
+}
+
+var instancesUpdateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Update a CMS instance from --name/--properties or a JSON --file.",
+	Args:  idArgs("Provide the id of the instance to update", nil),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var bodies []string
+		var client *cms.Client
+
+		payload, err := instancePayload()
+
+		if err == nil {
+			bodies, err = cms.InstanceBodiesFromJSON(payload)
+		}
+
+		if err == nil && len(bodies) != 1 {
+			err = errors.New("Update requires exactly one instance body")
+			logutil.LogError(err)
+		}
+
+		if err == nil {
+			client, err = newClient()
+		}
+
+		if err == nil {
+			err = client.UpdateInstanceById(cmd.Context(), instanceCategory, instanceType, bodies[0], args[0])
+		}
+
+		return err
+	},
+}
+
+var instancesDeleteCmd = &cobra.Command{
+	Use:   "delete [id]",
+	Short: "Delete a CMS instance by id, or every instance of the type with --all.",
+	Args:  idArgs("Provide an instance id or use --all to delete every instance of the type", func() bool { return deleteAllInstances }),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var client *cms.Client
+
+		client, err = newClient()
+
+		if err == nil && deleteAllInstances {
+			_, err = client.DeleteInstancesByType(cmd.Context(), instanceCategory, instanceType)
+		} else if err == nil {
+			err = client.DeleteInstanceById(cmd.Context(), instanceCategory, instanceType, args[0])
+		}
+
+		return
+	},
+}
+
+// Builds the instance payload from either the --file flag or the --name and --properties flags.
+func instancePayload() (payload string, err error) {
+	if len(instanceFile) > 0 {
+		payload, err = ioutil.ReadFileAsString(instanceFile)
+	} else if len(instanceName) > 0 {
+		payload, err = cms.NewInstanceBodyJSON(instanceName, instanceProperties)
+	} else {
+		err = errors.New("Provide either --file or --name with optional --properties")
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+func init() {
+	instancesCmd.PersistentFlags().StringVar(&instanceCategory, "category", cms.PlanetCategory, "CMS category of the instances")
+	instancesCmd.PersistentFlags().StringVar(&instanceType, "type", "", "CMS system type name of the instances, e.g. un_planet")
+	instancesCmd.MarkPersistentFlagRequired("type")
+
+	for _, c := range []*cobra.Command{instancesCreateCmd, instancesUpdateCmd} {
+		c.Flags().StringVar(&instanceName, "name", "", "Name of the instance")
+		c.Flags().StringVar(&instanceProperties, "properties", "", "JSON object of instance properties")
+		c.Flags().StringVar(&instanceFile, "file", "", "Path to a JSON file holding an instance body or an array of instance bodies")
+	}
+
+	addOutputFlags(instancesListCmd)
+	addListFlags(instancesListCmd)
+	addOutputFlags(instancesGetCmd)
+	instancesGetCmd.Flags().StringVar(&getName, "name", "", "Name of the instance to get instead of its id")
+
+	instancesDeleteCmd.Flags().BoolVar(&deleteAllInstances, "all", false, "Delete every instance of the type")
+
+	instancesCmd.AddCommand(instancesListCmd)
+	instancesCmd.AddCommand(instancesGetCmd)
+	instancesCmd.AddCommand(instancesCreateCmd)
+	instancesCmd.AddCommand(instancesUpdateCmd)
+	instancesCmd.AddCommand(instancesDeleteCmd)
+
+	PlanetsCmd.AddCommand(instancesCmd)
+}
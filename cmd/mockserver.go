@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"ocp/sample/planets/internal/config"
+	"ocp/sample/planets/internal/mockcms"
+	logutil "ocp/sample/planets/internal/util/log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var mockAddr string
+var mockCredentials string
+var mockUser string
+var mockOptions mockcms.Options
+
+var mockServerCmd = &cobra.Command{
+	Use:   "mock-server",
+	Short: "Run an in-memory fake of the OCP token and CMS instance endpoints for local development.",
+	Long: `Runs an in-memory fake of the OCP token endpoint and the CMS instance endpoints.
+Point the cli at it with --base-url http://<addr> and any tenant id. Data is lost when the server stops.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		mockOptions.ClientId, mockOptions.ClientSecret, _ = strings.Cut(mockCredentials, ":")
+		mockOptions.Username, mockOptions.Password, _ = strings.Cut(mockUser, ":")
+
+		if mockOptions.FaultRate < 0 || mockOptions.FaultRate > 1 {
+			err = &config.Error{Message: fmt.Sprintf("Invalid fault rate %v, expected a value between 0 and 1", mockOptions.FaultRate)}
+			logutil.LogError(err)
+			return
+		}
+
+		server := mockcms.NewServer(mockOptions)
+
+		logutil.Log(logutil.INFO_LEVEL, "Mock CMS server listening", "url", "http://"+mockAddr)
+		err = http.ListenAndServe(mockAddr, server)
+
+		if err != nil {
+			logutil.LogError(err)
+		}
+
+		return
+	},
+}
+
+func init() {
+	mockServerCmd.Flags().StringVar(&mockAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	mockServerCmd.Flags().StringVar(&mockCredentials, "credentials", "", "Client id and secret accepted by the token endpoint as <id>:<secret> (any client is accepted when empty)")
+	mockServerCmd.Flags().StringVar(&mockUser, "user", "", "Username and password accepted by the password grant as <username>:<password> (any user is accepted when empty)")
+	mockServerCmd.Flags().IntVar(&mockOptions.PageSize, "default-page-size", mockcms.DefaultPageSize, "Items per page when the request doesn't ask for a page size")
+	mockServerCmd.Flags().DurationVar(&mockOptions.TokenLifetime, "token-lifetime", mockcms.DefaultTokenLifetime, "How long issued access tokens are valid, e.g. 30s")
+	mockServerCmd.Flags().Float64Var(&mockOptions.FaultRate, "fault-rate", 0, "Share of CMS requests, between 0 and 1, that fail with --fault-status")
+	mockServerCmd.Flags().IntVar(&mockOptions.FaultStatus, "fault-status", http.StatusServiceUnavailable, "Status code returned by injected faults, e.g. 429 or 500")
+
+	PlanetsCmd.AddCommand(mockServerCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"ocp/sample/planets/internal/cms"
+
+	"github.com/spf13/cobra"
+)
+
+var planOptions cms.SyncOptions
+var planOutPath string
+
+var planCmd = &cobra.Command{
+	Use:     "plan",
+	Aliases: []string{"diff"},
+	Short:   "Show what a sync would create, update and delete without changing CMS.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var plan cms.Plan
+		var client *cms.Client
+
+		err := defaultDataPath(&planOptions)
+
+		if err == nil {
+			client, err = newClient()
+		}
+
+		if err == nil {
+			plan, err = client.MakePlan(cmd.Context(), planOptions)
+		}
+
+		if err == nil {
+			cms.PrintPlan(plan)
+		}
+
+		if err == nil && len(planOutPath) > 0 {
+			err = client.SavePlan(plan, planOutPath)
+		}
+
+		return err
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan file>",
+	Short: "Apply a saved plan, provided CMS hasn't changed since it was made.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var plan cms.Plan
+
+		client, err := newClient()
+
+		if err == nil {
+			plan, err = client.LoadPlan(args[0])
+		}
+
+		if err == nil {
+			_, err = client.ApplyPlan(cmd.Context(), plan)
+		}
+
+		return err
+	},
+}
+
+func init() {
+	addSyncFlags(planCmd, &planOptions)
+	planCmd.Flags().StringVar(&planOutPath, "out", "", "Save the plan to a file so it can be applied later")
+
+	PlanetsCmd.AddCommand(planCmd)
+	PlanetsCmd.AddCommand(applyCmd)
+}
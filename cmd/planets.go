@@ -1,8 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"ocp/sample/planets/internal/cms"
+	"ocp/sample/planets/internal/config"
+	logutil "ocp/sample/planets/internal/util/log"
+	outpututil "ocp/sample/planets/internal/util/output"
+	sdk "ocp/sample/planets/pkg/cms"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -11,49 +20,430 @@ var PlanetsCmd = &cobra.Command{
 	Use:   "planets",
 	Short: "A cli to manage CMS data",
 	Long:  `This cli creates, updates, deletes and prints CMS instance data.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments are valid by now, and errors from here on are logged where they happen.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		err := initLogging(cmd)
+
+		initConfig()
+
+		if err == nil {
+			err = initOutput()
+		}
+
+		if err == nil {
+			err = checkCassetteFlags()
+		}
+
+		if err == nil {
+			err = initRateLimit(cmd)
+		}
+
+		if err == nil {
+			err = initTokenCache()
+		}
+
+		return err
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		return closeAll()
+	},
 }
 
+// Files and clients opened for the command, closed in reverse order once it has run.
+var closers []io.Closer
+
+var pageSize int
+var maxItems int
+var dryRun bool
+var rateLimit float64
+var concurrency int
+var tokenCache bool
+var tokenCacheDir string
+var profile string
+var baseUrl string
+var tenantId string
+var confClientId string
+var sampleDataPath string
+var redactPaths []string
+var logLevel string
+var logFormat string
+var logFile string
+var outputFormat string
+var outputFields []string
+var getName string
+var filters []string
+var sorts []string
+var recordPath string
+var replayPath string
+
 var cmsCreatePlanetsCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create planet CMS instances based on sample data.",
-	Run: func(cmd *cobra.Command, args []string) {
-		cms.CreatePlanets()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+
+		if err == nil {
+			_, err = client.CreatePlanets(cmd.Context())
+		}
+
+		return err
 	},
 }
 
 var cmsUpdatePlanetsCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update planet CMS instances.",
-	Run: func(cmd *cobra.Command, args []string) {
-		cms.UpdatePlanets()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+
+		if err == nil {
+			_, err = client.UpdatePlanets(cmd.Context())
+		}
+
+		return err
 	},
 }
 
 var cmsDeletePlanetsCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Delete planet CMS instances.",
-	Run: func(cmd *cobra.Command, args []string) {
-		cms.DeletePlanets()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+
+		if err == nil {
+			_, err = client.DeletePlanets(cmd.Context())
+		}
+
+		return err
 	},
 }
 
 var cmsInfoPlanetsCmd = &cobra.Command{
 	Use:   "info",
-	Short: "Print planet CMS instance info.",
-	Run: func(cmd *cobra.Command, args []string) {
-		cms.PlanetInfo()
+	Short: "Print planet CMS instance info to stdout.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		listOpts := sdk.ListOptions{}
+
+		if err == nil {
+			listOpts, err = listOptions()
+		}
+
+		if err == nil {
+			err = client.PlanetInfo(cmd.Context(), listOpts)
+		}
+
+		return err
 	},
 }
 
+var cmsGetPlanetCmd = &cobra.Command{
+	Use:   "get [id]",
+	Short: "Print a single planet CMS instance, by id or by --name.",
+	Args:  idArgs("Provide either an instance id or --name", func() bool { return len(getName) > 0 }),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var client *cms.Client
+
+		client, err = newClient()
+
+		if err == nil && len(getName) > 0 {
+			err = client.PlanetByNameInfo(cmd.Context(), getName)
+		} else if err == nil {
+			err = client.PlanetByIdInfo(cmd.Context(), args[0])
+		}
+
+		return
+	},
+}
+
+// Validates the instance id argument: exactly one non-empty id, or none when the command is told which
+// instances to use another way, e.g. with --name. An empty id would address the whole collection instead.
+func idArgs(usage string, withoutId func() bool) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) (err error) {
+		idExpected := withoutId == nil || !withoutId()
+
+		switch {
+		case idExpected && len(args) != 1, !idExpected && len(args) > 0:
+			err = &config.Error{Message: usage}
+		case idExpected && len(strings.TrimSpace(args[0])) == 0:
+			err = &config.Error{Message: "The instance id can't be empty"}
+		}
+
+		return
+	}
+}
+
+// Passes the profile and connection flags to the config package, where they take precedence over the environment.
+func initConfig() {
+	config.SetProfile(profile)
+	config.SetOverride(config.VAR_BASE_URL, baseUrl)
+	config.SetOverride(config.VAR_TENANT_ID, tenantId)
+	config.SetOverride(config.VAR_CONF_CLIENT_ID, confClientId)
+	config.SetOverride(config.VAR_SAMPLE_DATA_PATH, sampleDataPath)
+}
+
+// Sets the log level, format and destination from the flags when set, otherwise the environment.
+// Logs go to stderr unless a log file is given.
+func initLogging(cmd *cobra.Command) (err error) {
+	var levelId logutil.LogLevelId
+	var file *os.File
+
+	if !cmd.Flags().Changed("log-level") {
+		logLevel = config.LogLevel()
+	}
+
+	if !cmd.Flags().Changed("log-format") {
+		logFormat = config.LogFormat()
+	}
+
+	if !cmd.Flags().Changed("log-file") {
+		logFile = config.LogFile()
+	}
+
+	levelId, err = logutil.ParseLevel(logLevel)
+
+	if err == nil {
+		logutil.SetLevel(levelId)
+		err = logutil.SetFormat(logFormat)
+	}
+
+	if err == nil && len(logFile) > 0 {
+		file, err = os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+
+		if err == nil {
+			logutil.SetOutput(file)
+			closers = append(closers, logFileCloser{file})
+		}
+	}
+
+	if err != nil {
+		err = &config.Error{Message: fmt.Sprintf("Invalid logging configuration: %s", err)}
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+// Sets how results are printed from the --output and --fields flags.
+func initOutput() (err error) {
+	err = outpututil.SetOptions(outpututil.Options{Format: outputFormat, Fields: outputFields})
+
+	if err != nil {
+		err = &config.Error{Message: fmt.Sprintf("Invalid output options: %s", err)}
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+// Adds the --output and --fields flags to a command that prints results.
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", outpututil.FORMAT_TABLE, "Output format: "+strings.Join(outpututil.Formats, ", "))
+	cmd.Flags().StringSliceVar(&outputFields, "fields", nil, "Fields to print, e.g. id,name,diameter or properties.diameter")
+}
+
+// Adds the --filter and --sort flags to a command that lists instances.
+func addListFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&filters, "filter", nil, "Only list instances matching a filter, e.g. 'diameter > 10000'. Repeat to combine filters.")
+	cmd.Flags().StringSliceVar(&sorts, "sort", nil, "Sort by a field, e.g. name, diameter:desc or -diameter")
+}
+
+// Builds the server-side list options from the --filter and --sort flags. Only the --fields are fetched when given.
+func listOptions() (listOpts sdk.ListOptions, err error) {
+	for _, expression := range filters {
+		var filter sdk.Filter
+
+		if filter, err = sdk.ParseFilter(expression); err != nil {
+			logutil.LogError(err)
+			return
+		}
+
+		listOpts.Filters = append(listOpts.Filters, filter)
+	}
+
+	for _, expression := range sorts {
+		var sort sdk.Sort
+
+		if sort, err = sdk.ParseSort(expression); err != nil {
+			logutil.LogError(err)
+			return
+		}
+
+		listOpts.Sort = append(listOpts.Sort, sort)
+	}
+
+	listOpts.Properties = outputFields
+
+	return
+}
+
+// Only one of --record and --replay can be given.
+func checkCassetteFlags() (err error) {
+	if len(recordPath) > 0 && len(replayPath) > 0 {
+		err = &config.Error{Message: "Use either --record or --replay, not both"}
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+// Uses the --rate-limit flag when set, otherwise the environment, otherwise the CMS default.
+func initRateLimit(cmd *cobra.Command) (err error) {
+	if !cmd.Flags().Changed("rate-limit") {
+		rateLimit, err = config.RateLimit(sdk.DefaultRateLimit)
+	}
+
+	// Replayed responses don't touch the network, so there's nothing to limit.
+	if len(replayPath) > 0 {
+		rateLimit = 0
+	}
+
+	return
+}
+
+// Enables the on-disk token cache when asked for by the --token-cache flag or the environment.
+func initTokenCache() (err error) {
+	// Replayed tokens are redacted, so they must not replace a real cached token.
+	if (tokenCache || config.TokenCacheEnabled()) && len(replayPath) == 0 {
+		tokenCacheDir, err = config.TokenCacheDir()
+	}
+
+	return
+}
+
+// Builds the CMS client for a command from the flags and configuration. Only commands that talk to CMS
+// create one, so commands that work offline don't need a base url or credentials.
+func newClient() (client *cms.Client, err error) {
+	var cmsBaseUrl string
+
+	cmsBaseUrl, err = config.BaseUrl()
+
+	if err == nil {
+		client, err = cms.NewClient(cms.Options{
+			Options: sdk.Options{
+				BaseUrl:       cmsBaseUrl,
+				RateLimit:     rateLimit,
+				Credentials:   config.Credentials,
+				TokenCacheDir: tokenCacheDir,
+				Logger:        logutil.Default,
+				DryRun:        dryRun,
+				PageOptions:   sdk.PageOptions{PageSize: pageSize},
+				RedactPaths:   append(config.RedactPaths(), redactPaths...),
+			},
+			Concurrency: concurrency,
+			MaxItems:    maxItems,
+		})
+
+		if err != nil {
+			err = &config.Error{Message: err.Error()}
+			logutil.LogError(err)
+		}
+	}
+
+	if err == nil {
+		err = initCassette(client)
+	}
+
+	if err == nil {
+		closers = append(closers, client)
+	}
+
+	return
+}
+
+// Records the session to the --record cassette, or serves it from the --replay cassette.
+func initCassette(client *cms.Client) (err error) {
+	if len(recordPath) > 0 {
+		err = client.RecordTo(recordPath)
+	} else if len(replayPath) > 0 {
+		err = client.ReplayFrom(replayPath)
+	}
+
+	// The cause has already been logged.
+	if err != nil {
+		err = &config.Error{Message: fmt.Sprintf("Unable to open the cassette: %s", err)}
+	}
+
+	return
+}
+
 func Execute() {
-	err := PlanetsCmd.Execute()
+	// Ctrl-C cancels the command's context, so rate limit waits and requests in flight stop.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := PlanetsCmd.ExecuteContext(ctx)
+	stop()
+
+	// Cobra skips the post run when a command fails, so close what's still open here.
+	if closeErr := closeAll(); err == nil {
+		err = closeErr
+	}
+
+	os.Exit(exitCode(err))
+}
+
+// Closes the clients and the log file opened for the command, returning the first error.
+func closeAll() (err error) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		if closeErr := closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	closers = nil
+
+	return
+}
+
+// Flushes the log file to disk and closes it, sending any later logs back to stderr.
+type logFileCloser struct {
+	file *os.File
+}
+
+func (c logFileCloser) Close() (err error) {
+	logutil.SetOutput(os.Stderr)
+
+	err = c.file.Sync()
+
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+
 	if err != nil {
-		os.Exit(1)
+		logutil.LogError(err)
 	}
+
+	return
 }
 
 func init() {
+	PlanetsCmd.PersistentFlags().StringVar(&profile, "profile", "", "Named profile from the config file to use")
+	PlanetsCmd.PersistentFlags().StringVar(&baseUrl, "base-url", "", "OCP base url, overriding the environment and profile")
+	PlanetsCmd.PersistentFlags().StringVar(&tenantId, "tenant-id", "", "OCP tenant id, overriding the environment and profile")
+	PlanetsCmd.PersistentFlags().StringVar(&confClientId, "client-id", "", "OCP confidential client id, overriding the environment and profile")
+	PlanetsCmd.PersistentFlags().StringVar(&sampleDataPath, "data-path", "", "Path to the sample planet data, overriding the environment and profile")
+	PlanetsCmd.PersistentFlags().IntVar(&pageSize, "page-size", sdk.DefaultPageSize, "Number of instances requested per page when listing")
+	PlanetsCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Log create, update and delete requests instead of sending them")
+	PlanetsCmd.PersistentFlags().IntVar(&concurrency, "concurrency", cms.DefaultConcurrency, "Maximum number of create, update or delete requests in flight at once")
+	PlanetsCmd.PersistentFlags().Float64Var(&rateLimit, "rate-limit", sdk.DefaultRateLimit, "Maximum requests per second sent to OCP (0 disables limiting)")
+	PlanetsCmd.PersistentFlags().BoolVar(&tokenCache, "token-cache", false, "Cache the access token on disk so later runs can reuse it until it expires")
+	PlanetsCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Least severe level logged: debug, info, warn or error")
+	PlanetsCmd.PersistentFlags().StringVar(&logFormat, "log-format", logutil.FORMAT_TEXT, "Log format: text or json")
+	PlanetsCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Append logs to this file instead of writing them to stderr")
+	PlanetsCmd.PersistentFlags().StringSliceVar(&redactPaths, "redact", nil, "JSON paths, e.g. properties.code, whose values are masked in log output")
+	PlanetsCmd.PersistentFlags().StringVar(&recordPath, "record", "", "Record every request and response, with secrets redacted, to a cassette file")
+	PlanetsCmd.PersistentFlags().StringVar(&replayPath, "replay", "", "Serve responses from a cassette file recorded with --record instead of the network")
+	PlanetsCmd.PersistentFlags().IntVar(&maxItems, "max-items", 0, "Maximum number of instances printed by info (0 prints every instance)")
+
+	addOutputFlags(cmsInfoPlanetsCmd)
+	addListFlags(cmsInfoPlanetsCmd)
+	addOutputFlags(cmsGetPlanetCmd)
+	cmsGetPlanetCmd.Flags().StringVar(&getName, "name", "", "Name of the instance to get instead of its id")
+
 	PlanetsCmd.AddCommand(cmsInfoPlanetsCmd)
+	PlanetsCmd.AddCommand(cmsGetPlanetCmd)
 	PlanetsCmd.AddCommand(cmsCreatePlanetsCmd)
 	PlanetsCmd.AddCommand(cmsUpdatePlanetsCmd)
 	PlanetsCmd.AddCommand(cmsDeletePlanetsCmd)
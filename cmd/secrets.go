@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"ocp/sample/planets/internal/config"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the encrypted secrets file read by store:NAME secret references.",
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of the secrets in the secrets file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		secrets, _, err := loadSecrets()
+
+		if err == nil {
+			var names []string
+			for name := range secrets {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			for _, name := range names {
+				logutil.Log(logutil.INFO_LEVEL, name)
+			}
+
+			if len(names) == 0 {
+				path, _ := config.SecretsFilePath()
+				logutil.Log(logutil.INFO_LEVEL, "No secrets found", "path", path)
+			}
+		}
+
+		return err
+	},
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Add or replace a secret. The value is prompted for, or read from standard input when it isn't a terminal.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var value string
+
+		secrets, passphrase, err := loadSecrets()
+
+		if err == nil {
+			value, err = readSecretValue(args[0])
+		}
+
+		if err == nil {
+			secrets[args[0]] = value
+			err = config.SaveSecrets(secrets, passphrase)
+		}
+
+		if err == nil {
+			logutil.Log(logutil.INFO_LEVEL, "Saved secret", "name", args[0], "reference", config.SECRET_STORE_PREFIX+args[0])
+		}
+
+		return err
+	},
+}
+
+var secretsRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a secret from the secrets file.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		secrets, passphrase, err := loadSecrets()
+
+		if _, ok := secrets[args[0]]; err == nil && !ok {
+			err = &config.Error{Message: fmt.Sprintf("Secret %s is not in the secrets file.", args[0])}
+			logutil.LogError(err)
+		}
+
+		if err == nil {
+			delete(secrets, args[0])
+			err = config.SaveSecrets(secrets, passphrase)
+		}
+
+		if err == nil {
+			logutil.Log(logutil.INFO_LEVEL, "Removed secret", "name", args[0])
+		}
+
+		return err
+	},
+}
+
+// Unlocks the secrets file, asking for the passphrase twice when the file is about to be created.
+func loadSecrets() (secrets map[string]string, passphrase string, err error) {
+	passphrase, err = config.SecretsPassphrase(!config.SecretsFileExists())
+
+	if err == nil {
+		secrets, err = config.LoadSecrets(passphrase)
+	}
+
+	return
+}
+
+// Reads a secret value without echoing it, or from piped standard input for scripts.
+func readSecretValue(name string) (value string, err error) {
+	var valueBytes []byte
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+		valueBytes, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+	} else {
+		valueBytes, err = io.ReadAll(os.Stdin)
+	}
+
+	value = strings.TrimRight(string(valueBytes), "\r\n")
+
+	if err == nil && len(value) == 0 {
+		err = &config.Error{Message: fmt.Sprintf("No value given for secret %s.", name)}
+	}
+
+	if err != nil {
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsRemoveCmd)
+
+	PlanetsCmd.AddCommand(secretsCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"ocp/sample/planets/internal/cms"
+	"ocp/sample/planets/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var syncOptions cms.SyncOptions
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Create, update and optionally delete CMS instances so they match a data file.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var client *cms.Client
+
+		err := defaultDataPath(&syncOptions)
+
+		if err == nil {
+			client, err = newClient()
+		}
+
+		if err == nil {
+			_, err = client.Sync(cmd.Context(), syncOptions)
+		}
+
+		return err
+	},
+}
+
+// Registers the flags that choose the data file, type and matching rules for a sync.
+func addSyncFlags(cmd *cobra.Command, opts *cms.SyncOptions) {
+	cmd.Flags().StringVar(&opts.DataPath, "file", "", "Path to the JSON data file (defaults to the sample planet data)")
+	cmd.Flags().StringVar(&opts.Category, "category", cms.PlanetCategory, "CMS category of the instances")
+	cmd.Flags().StringVar(&opts.SystemTypeName, "type", cms.PlanetType, "CMS system type name of the instances")
+	cmd.Flags().StringVar(&opts.Key, "key", cms.DefaultSyncKey, "Name or property used to match records to instances")
+	cmd.Flags().BoolVar(&opts.Prune, "prune", false, "Delete instances that are not in the data file")
+}
+
+// Falls back to the sample planet data when no data file was given.
+func defaultDataPath(opts *cms.SyncOptions) (err error) {
+	if len(opts.DataPath) == 0 {
+		opts.DataPath, err = config.SampleDataPath()
+	}
+
+	return
+}
+
+func init() {
+	addSyncFlags(syncCmd, &syncOptions)
+
+	PlanetsCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"ocp/sample/planets/internal/cms"
+
+	"github.com/spf13/cobra"
+)
+
+var validateFile string
+var validateType string
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate a data file against the CMS type model without calling CMS.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(validateFile) > 0 {
+			return cms.ValidateDataFile(validateType, validateFile)
+		}
+
+		return cms.ValidatePlanets()
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&validateFile, "file", "", "Path to the JSON data file (defaults to the sample planet data)")
+	validateCmd.Flags().StringVar(&validateType, "type", cms.PlanetType, "CMS system type name to validate against")
+
+	PlanetsCmd.AddCommand(validateCmd)
+}
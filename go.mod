@@ -7,6 +7,9 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/spf13/cobra v1.7.0
 	github.com/tidwall/gjson v1.17.0
+	golang.org/x/crypto v0.11.0
+	golang.org/x/term v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,4 +18,5 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
+	golang.org/x/sys v0.20.0 // indirect
 )
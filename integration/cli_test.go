@@ -0,0 +1,603 @@
+// Runs the planets cli against the mock CMS server. The cli is built once and every command runs
+// as a separate process, as it would from a script, so exit codes and stdout can be checked.
+package integration
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"ocp/sample/planets/internal/cms"
+	"ocp/sample/planets/internal/mockcms"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	clientId     = "integration-client"
+	clientSecret = "integration-secret"
+	tenantId     = "integration-tenant"
+)
+
+var binary string
+var repoRoot string
+
+func TestMain(m *testing.M) {
+	os.Exit(buildAndRun(m))
+}
+
+func buildAndRun(m *testing.M) int {
+	var err error
+
+	repoRoot, err = filepath.Abs("..")
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	dir, err := os.MkdirTemp("", "planets-integration")
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	defer os.RemoveAll(dir)
+
+	binary = filepath.Join(dir, "planets")
+
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = repoRoot
+	build.Stderr = os.Stderr
+
+	if err = build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to build the cli:", err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// A mock CMS server and the environment pointing the cli at it
+type harness struct {
+	t      *testing.T
+	server *mockcms.Server
+	dir    string
+	env    []string
+}
+
+type result struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+func newHarness(t *testing.T, opts mockcms.Options) *harness {
+	if opts.ClientId == "" {
+		opts.ClientId, opts.ClientSecret = clientId, clientSecret
+	}
+
+	server := mockcms.NewServer(opts)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	// Run from an empty directory so a developer's .env isn't loaded.
+	dir := t.TempDir()
+
+	return &harness{
+		t:      t,
+		server: server,
+		dir:    dir,
+		env: []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + dir,
+			"NO_COLOR=1",
+			"CMS_DEMO_BASE_URL=" + httpServer.URL,
+			"CMS_DEMO_TENANT_ID=" + tenantId,
+			"CMS_DEMO_CONF_CLIENT_ID=" + clientId,
+			"CMS_DEMO_CLIENT_SECRET=" + clientSecret,
+			"CMS_DEMO_CONFIG_FILE=" + filepath.Join(dir, "config.yaml"),
+			"CMS_DEMO_SAMPLE_DATA_PATH=" + filepath.Join(repoRoot, "data", "planet-data.json"),
+			"CMS_DEMO_PROJECT_PATH=" + filepath.Join(repoRoot, ".otproject"),
+			"CMS_DEMO_RATE_LIMIT=0",
+		},
+	}
+}
+
+// Runs the cli with extra environment variables, e.g. to override the client secret.
+func (h *harness) runWithEnv(env []string, stdin string, args ...string) (r result) {
+	var stdout, stderr bytes.Buffer
+	var exitErr *exec.ExitError
+
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = h.dir
+	cmd.Env = append(append([]string{}, h.env...), env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	if errors.As(err, &exitErr) {
+		r.code = exitErr.ExitCode()
+	} else if err != nil {
+		h.t.Fatalf("Unable to run planets %s: %s", strings.Join(args, " "), err)
+	}
+
+	r.stdout, r.stderr = stdout.String(), stderr.String()
+
+	return
+}
+
+func (h *harness) run(args ...string) result {
+	return h.runWithEnv(nil, "", args...)
+}
+
+// Runs the cli and fails the test unless it exits with the expected code.
+func (h *harness) expect(code int, args ...string) result {
+	h.t.Helper()
+
+	r := h.run(args...)
+
+	if r.code != code {
+		h.t.Fatalf("planets %s exited with %d, expected %d\nstdout:\n%s\nstderr:\n%s", strings.Join(args, " "), r.code, code, r.stdout, r.stderr)
+	}
+
+	return r
+}
+
+func (h *harness) planets() []mockcms.Instance {
+	return h.server.Instances(cms.PlanetCategory, cms.PlanetType)
+}
+
+func (h *harness) seedPlanets(names ...string) {
+	for i, name := range names {
+		h.server.Seed(cms.PlanetCategory, cms.PlanetType, name, map[string]interface{}{"diameter": (i + 1) * 1000, "length_of_day": 24})
+	}
+}
+
+func parseJSON(t *testing.T, stdout string) gjson.Result {
+	t.Helper()
+
+	if !gjson.Valid(stdout) {
+		t.Fatalf("Expected JSON on stdout, got:\n%s", stdout)
+	}
+
+	return gjson.Parse(stdout)
+}
+
+func names(records gjson.Result) (names []string) {
+	for _, record := range records.Array() {
+		names = append(names, record.Get("name").String())
+	}
+
+	return
+}
+
+func TestPlanetLifecycle(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+
+	h.expect(0, "validate")
+
+	h.expect(0, "create")
+
+	if count := len(h.planets()); count != 8 {
+		t.Fatalf("Expected 8 planets after create, found %d", count)
+	}
+
+	h.expect(0, "update")
+
+	for _, planet := range h.planets() {
+		if _, ok := planet.Properties["number_of_moons"]; !ok {
+			t.Errorf("Expected update to set number_of_moons on %s", planet.Name)
+		}
+	}
+
+	planets := parseJSON(t, h.expect(0, "info", "-o", "json").stdout)
+
+	if count := len(planets.Array()); count != 8 {
+		t.Errorf("Expected info to print 8 planets, printed %d", count)
+	}
+
+	earth := parseJSON(t, h.expect(0, "get", "--name", "Earth", "-o", "json").stdout)
+
+	if earth.Get("properties.number_of_moons").Int() != 1 {
+		t.Errorf("Expected Earth to have 1 moon, got %s", earth.Raw)
+	}
+
+	byId := parseJSON(t, h.expect(0, "get", earth.Get("id").String(), "-o", "json").stdout)
+
+	if byId.Get("name").String() != "Earth" {
+		t.Errorf("Expected get by id to print Earth, got %s", byId.Raw)
+	}
+
+	if out := h.expect(0, "info", "-o", "json", "--max-items", "2").stdout; len(parseJSON(t, out).Array()) != 2 {
+		t.Errorf("Expected --max-items to limit info to 2 planets, got %s", out)
+	}
+
+	h.expect(0, "delete", "--max-items", "2")
+
+	if count := len(h.planets()); count != 0 {
+		t.Fatalf("Expected delete to ignore --max-items and remove every planet, found %d", count)
+	}
+
+	if out := h.expect(0, "info", "-o", "json").stdout; strings.TrimSpace(out) != "[]" {
+		t.Errorf("Expected an empty JSON array, got %s", out)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Mercury", "Venus")
+
+	table := h.expect(0, "info").stdout
+
+	if !strings.HasPrefix(table, "ID") || !strings.Contains(table, "Venus") {
+		t.Errorf("Unexpected table output:\n%s", table)
+	}
+
+	csv := h.expect(0, "info", "-o", "csv", "--fields", "name,diameter").stdout
+
+	if csv != "name,diameter\nMercury,1000\nVenus,2000\n" {
+		t.Errorf("Unexpected CSV output:\n%s", csv)
+	}
+
+	ndjson := h.expect(0, "info", "-o", "ndjson", "--fields", "name").stdout
+
+	if ndjson != "{\"name\":\"Mercury\"}\n{\"name\":\"Venus\"}\n" {
+		t.Errorf("Unexpected NDJSON output:\n%s", ndjson)
+	}
+
+	yaml := h.expect(0, "info", "-o", "yaml", "--fields", "name").stdout
+
+	if yaml != "- name: Mercury\n- name: Venus\n" {
+		t.Errorf("Unexpected YAML output:\n%s", yaml)
+	}
+
+	h.expect(2, "info", "-o", "xml")
+}
+
+func TestPagingFilterAndSort(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+
+	var seeded []string
+	for i := 1; i <= 25; i++ {
+		seeded = append(seeded, fmt.Sprintf("Planet %02d", i))
+	}
+	h.seedPlanets(seeded...)
+
+	all := parseJSON(t, h.expect(0, "info", "-o", "json", "--page-size", "10").stdout)
+
+	if count := len(all.Array()); count != 25 {
+		t.Errorf("Expected every page to be listed, got %d planets", count)
+	}
+
+	limited := parseJSON(t, h.expect(0, "info", "-o", "json", "--page-size", "10", "--max-items", "12").stdout)
+
+	if count := len(limited.Array()); count != 12 {
+		t.Errorf("Expected --max-items to stop at 12 planets, got %d", count)
+	}
+
+	// A page failing mid-stream still leaves a complete JSON array on stdout.
+	h.server.InjectFaults(0, 1)
+	h.server.InjectFaults(http.StatusBadRequest, 1)
+	partial := h.run("info", "-o", "json", "--page-size", "10")
+
+	if count := len(parseJSON(t, partial.stdout).Array()); partial.code != 1 || count != 10 {
+		t.Errorf("Expected the first page and exit code 1 when the second page fails, got %d planets and exit code %d", count, partial.code)
+	}
+
+	filtered := parseJSON(t, h.expect(0, "info", "-o", "json", "--filter", "diameter > 20000", "--sort", "-diameter").stdout)
+
+	if got := strings.Join(names(filtered), ","); got != "Planet 25,Planet 24,Planet 23,Planet 22,Planet 21" {
+		t.Errorf("Unexpected filtered and sorted planets: %s", got)
+	}
+
+	h.expect(1, "info", "--filter", "diameter")
+}
+
+func TestGetErrors(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Earth", "Earth")
+
+	if r := h.expect(6, "get", "no-such-id"); !strings.Contains(r.stderr, "NOT_FOUND") || !strings.Contains(r.stderr, "request id: mock-request-") {
+		t.Errorf("Expected the CMS error code and request id to be reported, got:\n%s", r.stderr)
+	}
+
+	h.expect(6, "get", "--name", "Pluto")
+
+	if r := h.expect(1, "get", "--name", "Earth"); !strings.Contains(r.stderr, "Earth") {
+		t.Errorf("Expected the ambiguous name to be reported, got:\n%s", r.stderr)
+	}
+
+	h.expect(2, "get")
+	h.expect(2, "get", "")
+	h.expect(2, "get", "no-such-id", "--name", "Earth")
+	h.expect(2, "instances", "--type", cms.PlanetType, "get", " ")
+	h.expect(2, "instances", "--type", cms.PlanetType, "update", "", "--name", "Earth")
+	h.expect(2, "instances", "--type", cms.PlanetType, "delete", "")
+}
+
+func TestInstancesCommands(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	moon := []string{"instances", "--type", "un_moon"}
+
+	h.expect(0, append(moon, "create", "--name", "Luna", "--properties", `{"radius": 1737}`)...)
+
+	moons := h.server.Instances(cms.PlanetCategory, "un_moon")
+
+	if len(moons) != 1 || moons[0].Name != "Luna" {
+		t.Fatalf("Expected Luna to be created, found %v", moons)
+	}
+
+	id := moons[0].Id
+
+	h.expect(0, append(moon, "update", id, "--name", "Luna", "--properties", `{"radius": 1738}`)...)
+
+	luna := parseJSON(t, h.expect(0, append(moon, "get", id, "-o", "json")...).stdout)
+
+	if luna.Get("properties.radius").Int() != 1738 {
+		t.Errorf("Expected the radius to be updated, got %s", luna.Raw)
+	}
+
+	listed := parseJSON(t, h.expect(0, append(moon, "list", "-o", "json")...).stdout)
+
+	if got := strings.Join(names(listed), ","); got != "Luna" {
+		t.Errorf("Expected Luna to be listed, got %s", got)
+	}
+
+	h.expect(0, append(moon, "delete", id)...)
+	h.expect(6, append(moon, "get", id)...)
+
+	h.server.Seed(cms.PlanetCategory, "un_moon", "Phobos", nil)
+	h.server.Seed(cms.PlanetCategory, "un_moon", "Deimos", nil)
+	h.expect(0, append(moon, "delete", "--all")...)
+
+	if count := len(h.server.Instances(cms.PlanetCategory, "un_moon")); count != 0 {
+		t.Errorf("Expected every moon to be deleted, found %d", count)
+	}
+
+	h.expect(1, append(moon, "create")...)
+}
+
+func TestSyncPlanAndApply(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Earth", "Pluto")
+
+	planPath := filepath.Join(h.dir, "plan.json")
+
+	if r := h.expect(0, "plan", "--prune", "--out", planPath); !strings.Contains(r.stdout, "7 to create") || !strings.Contains(r.stdout, "1 to delete") {
+		t.Errorf("Unexpected plan:\n%s", r.stdout)
+	}
+
+	if count := len(h.planets()); count != 2 {
+		t.Fatalf("Expected plan not to change CMS, found %d planets", count)
+	}
+
+	h.expect(0, "apply", planPath)
+
+	if count := len(h.planets()); count != 8 {
+		t.Errorf("Expected 8 planets after apply, found %d", count)
+	}
+
+	h.server.Seed(cms.PlanetCategory, cms.PlanetType, "Vulcan", nil)
+	h.expect(0, "sync", "--prune")
+
+	for _, planet := range h.planets() {
+		if planet.Name == "Pluto" || planet.Name == "Vulcan" {
+			t.Errorf("Expected sync --prune to delete %s", planet.Name)
+		}
+	}
+
+	if r := h.expect(0, "plan", "--prune", "--out", planPath); !strings.Contains(r.stdout, "0 to create, 0 to update, 0 to delete") {
+		t.Errorf("Expected nothing left to sync, got:\n%s", r.stdout)
+	}
+
+	// Instances the plan leaves alone are still checked for drift.
+	for _, planet := range h.planets() {
+		if planet.Name == "Mars" {
+			h.server.SetProperty(cms.PlanetCategory, cms.PlanetType, planet.Id, "diameter", 1)
+		}
+	}
+
+	if r := h.expect(1, "apply", planPath); !strings.Contains(r.stderr, "Instance has changed since the plan was made") {
+		t.Errorf("Expected apply to report the changed instance, got:\n%s", r.stderr)
+	}
+
+	h.expect(0, "sync")
+	h.expect(0, "plan", "--out", planPath)
+	h.server.Seed(cms.PlanetCategory, cms.PlanetType, "Vulcan", nil)
+
+	if r := h.expect(1, "apply", planPath); !strings.Contains(r.stderr, "Instance has been created since the plan was made") {
+		t.Errorf("Expected apply to report the new instance, got:\n%s", r.stderr)
+	}
+
+	h.expect(0, "sync", "--prune")
+
+	h.expect(0, "sync", "--max-items", "2")
+
+	if count := len(h.planets()); count != 8 {
+		t.Errorf("Expected --max-items not to limit what sync compares against, found %d planets", count)
+	}
+}
+
+func TestSyncPrunesDuplicateOrphans(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Vulcan", "Vulcan")
+
+	h.expect(0, "sync", "--prune")
+
+	for _, planet := range h.planets() {
+		if planet.Name == "Vulcan" {
+			t.Errorf("Expected sync --prune to delete every Vulcan, found %s", planet.Id)
+		}
+	}
+
+	if count := len(h.planets()); count != 8 {
+		t.Errorf("Expected 8 planets after sync, found %d", count)
+	}
+}
+
+func TestConfigPrintsToStdout(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+
+	show := h.expect(0, "config", "show", "--log-level", "error")
+
+	if !strings.Contains(show.stdout, "Profile: ") || !strings.Contains(show.stdout, clientId) {
+		t.Errorf("Expected the settings on stdout, got:\n%s", show.stdout)
+	}
+
+	if strings.Contains(show.stdout, clientSecret) {
+		t.Errorf("Expected the client secret to be redacted, got:\n%s", show.stdout)
+	}
+
+	if list := h.expect(0, "config", "list", "--log-format", "json"); !strings.HasPrefix(list.stdout, "No profiles found") || len(list.stderr) > 0 {
+		t.Errorf("Expected the profile list on stdout only, got:\n%s\nstderr:\n%s", list.stdout, list.stderr)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+
+	if r := h.expect(0, "create", "--dry-run"); !strings.Contains(r.stderr, "[DRY RUN]") {
+		t.Errorf("Expected dry-run requests to be logged, got:\n%s", r.stderr)
+	}
+
+	if count := len(h.planets()); count != 0 {
+		t.Errorf("Expected dry run not to create planets, found %d", count)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+
+	if r := h.runWithEnv([]string{"CMS_DEMO_CLIENT_SECRET=wrong"}, "", "info"); r.code != 3 {
+		t.Errorf("Expected a rejected client secret to exit with 3, got %d\n%s", r.code, r.stderr)
+	}
+
+	if r := h.runWithEnv([]string{"CMS_DEMO_BASE_URL=not a url"}, "", "info"); r.code != 2 {
+		t.Errorf("Expected an invalid base url to exit with 2, got %d", r.code)
+	}
+}
+
+func TestExpiredTokenIsReplaced(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Earth")
+
+	cache := []string{"CMS_DEMO_TOKEN_CACHE=true", "CMS_DEMO_TOKEN_CACHE_DIR=" + h.dir}
+
+	if r := h.runWithEnv(cache, "", "info"); r.code != 0 {
+		t.Fatalf("Expected info to succeed, got %d\n%s", r.code, r.stderr)
+	}
+
+	// The cached token is still valid as far as the cli knows, so CMS rejects it and the request is replayed.
+	h.server.ExpireTokens()
+
+	r := h.runWithEnv(cache, "", "info", "-o", "json")
+
+	if r.code != 0 || len(names(parseJSON(t, r.stdout))) != 1 {
+		t.Errorf("Expected info to succeed with a new token, got %d\n%s", r.code, r.stderr)
+	}
+
+	if !strings.Contains(r.stderr, "Access token was rejected") {
+		t.Errorf("Expected the rejected token to be logged, got:\n%s", r.stderr)
+	}
+}
+
+func TestInjectedFaults(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Earth")
+
+	// Lists are retried, and the mock asks for an immediate retry on 429 and 503.
+	h.server.InjectFaults(http.StatusTooManyRequests, 1)
+	h.server.InjectFaults(http.StatusServiceUnavailable, 1)
+	h.expect(0, "info")
+
+	failing := newHarness(t, mockcms.Options{FaultRate: 1, FaultStatus: http.StatusInternalServerError})
+	failing.expect(5, "create")
+
+	if count := len(failing.planets()); count != 0 {
+		t.Errorf("Expected no planets to be created, found %d", count)
+	}
+
+	h.server.InjectFaults(http.StatusInternalServerError, 2)
+	r := h.run("instances", "--type", cms.PlanetType, "create", "--file", filepath.Join(repoRoot, "data", "planet-data.json"))
+
+	if r.code != 4 {
+		t.Errorf("Expected some creates to fail with exit code 4, got %d\n%s", r.code, r.stderr)
+	}
+}
+
+func TestPartialFailureExitCode(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Earth")
+
+	// Every planet but Earth is missing, so the first failure is a not-found error, but the batch still partly succeeded.
+	if r := h.run("update"); r.code != 4 {
+		t.Errorf("Expected a partly failed update to exit with 4, got %d\n%s", r.code, r.stderr)
+	}
+}
+
+func TestSecretsStore(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Earth")
+
+	store := []string{"CMS_DEMO_SECRETS_FILE=" + filepath.Join(h.dir, "secrets.enc"), "CMS_DEMO_SECRETS_PASSPHRASE=correct horse"}
+
+	if r := h.runWithEnv(store, clientSecret+"\n", "secrets", "set", "client"); r.code != 0 {
+		t.Fatalf("Expected the secret to be stored, got %d\n%s", r.code, r.stderr)
+	}
+
+	if r := h.runWithEnv(append(store, "CMS_DEMO_CLIENT_SECRET=store:client"), "", "info"); r.code != 0 {
+		t.Errorf("Expected the stored client secret to be used, got %d\n%s", r.code, r.stderr)
+	}
+
+	wrong := []string{store[0], "CMS_DEMO_SECRETS_PASSPHRASE=wrong", "CMS_DEMO_CLIENT_SECRET=store:client"}
+
+	if r := h.runWithEnv(wrong, "", "info"); r.code != 2 {
+		t.Errorf("Expected a wrong passphrase to exit with 2, got %d", r.code)
+	}
+
+	if r := h.runWithEnv(store, "", "info", "--log-level", "debug"); strings.Contains(r.stderr, clientSecret) {
+		t.Errorf("Expected the client secret to be redacted from logs, got:\n%s", r.stderr)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	h := newHarness(t, mockcms.Options{})
+	h.seedPlanets("Mercury", "Venus")
+
+	cassette := filepath.Join(h.dir, "session.ndjson")
+	recorded := h.expect(0, "info", "-o", "json", "--record", cassette).stdout
+
+	contents, err := os.ReadFile(cassette)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(contents), clientSecret) || strings.Contains(string(contents), "mock-access-token") {
+		t.Errorf("Expected secrets to be redacted from the cassette, got:\n%s", contents)
+	}
+
+	// Nothing listens on the base url, so every response must come from the cassette.
+	offline := []string{"CMS_DEMO_BASE_URL=http://127.0.0.1:1", "CMS_DEMO_CLIENT_SECRET=another-secret"}
+	r := h.runWithEnv(offline, "", "info", "-o", "json", "--replay", cassette)
+
+	if r.code != 0 || r.stdout != recorded {
+		t.Errorf("Expected the replay to print the recorded output, got %d\n%s\n%s", r.code, r.stdout, r.stderr)
+	}
+
+	if r := h.runWithEnv(offline, "", "delete", "--replay", cassette); r.code == 0 || !strings.Contains(r.stderr, "No recorded response") {
+		t.Errorf("Expected a request missing from the cassette to fail, got %d\n%s", r.code, r.stderr)
+	}
+
+	h.expect(2, "info", "--replay", cassette, "--record", cassette)
+}
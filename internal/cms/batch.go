@@ -0,0 +1,232 @@
+package cms
+
+import (
+	"context"
+	"fmt"
+	logutil "ocp/sample/planets/internal/util/log"
+	sdk "ocp/sample/planets/pkg/cms"
+	"sync"
+	"time"
+)
+
+const DefaultConcurrency = 5
+
+// A single item of work in a batch operation, e.g. creating one instance.
+// Key identifies the item in results and log messages, e.g. the instance name or id.
+type BatchTask struct {
+	Action string
+	Type   string
+	Key    string
+	Run    func() (statusCode int, err error)
+}
+
+// The outcome of a single item in a batch operation
+type BatchResult struct {
+	Action     string
+	Type       string
+	Key        string
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// An error reporting the items of a batch operation that failed
+type BatchError struct {
+	Action string
+	Failed []BatchResult
+	Total  int
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d %s request(s) failed", len(e.Failed), e.Total, e.Action)
+}
+
+// Reports whether every item in the batch failed.
+func (e *BatchError) AllFailed() bool {
+	return len(e.Failed) == e.Total
+}
+
+// Unwraps to the first item's error so callers can tell, for example, authentication failures apart.
+func (e *BatchError) Unwrap() error {
+	for _, result := range e.Failed {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+
+	return nil
+}
+
+// Reports whether the item succeeded: no error and a status code below 400.
+func (r BatchResult) Success() bool {
+	return r.Err == nil && r.StatusCode < 400
+}
+
+func (r BatchResult) String() string {
+	if r.Success() {
+		return fmt.Sprintf("%s %s succeeded (HTTP status code: %d)", r.Action, r.Key, r.StatusCode)
+	}
+
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s failed: %s", r.Action, r.Key, r.Err)
+	}
+
+	return fmt.Sprintf("%s %s failed (HTTP status code: %d)", r.Action, r.Key, r.StatusCode)
+}
+
+// Runs batch tasks on a bounded pool of workers and collects one result per task.
+// Results are returned in the same order as the tasks.
+func (c *Client) RunBatch(tasks []BatchTask) (results []BatchResult) {
+	results = make([]BatchResult, len(tasks))
+	indexes := make(chan int)
+	workers := c.concurrency
+
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				results[i] = c.runTask(tasks[i])
+			}
+		}()
+	}
+
+	for i := range tasks {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return
+}
+
+// Returns a BatchError if any item failed. Each failure has already been logged when its task ran.
+func (c *Client) CheckResults(action string, results []BatchResult) (err error) {
+	var failed []BatchResult
+
+	for _, result := range results {
+		if !result.Success() {
+			failed = append(failed, result)
+		}
+	}
+
+	if len(failed) > 0 {
+		err = &BatchError{Action: action, Failed: failed, Total: len(results)}
+		c.Logger.LogError(err)
+	}
+
+	return
+}
+
+func (c *Client) runTask(task BatchTask) (result BatchResult) {
+	result = BatchResult{Action: task.Action, Type: task.Type, Key: task.Key}
+
+	start := time.Now()
+	result.StatusCode, result.Err = task.Run()
+	result.Duration = time.Since(start)
+
+	if result.Success() {
+		c.Logger.Log(logutil.INFO_LEVEL, "Request succeeded", result.fields()...)
+	} else {
+		c.Logger.Log(logutil.ERROR_LEVEL, "Request failed", result.fields()...)
+	}
+
+	return
+}
+
+// Key/value pairs describing the result for structured logs
+func (r BatchResult) fields() (fields []any) {
+	fields = []any{"action", r.Action, "type", r.Type, "key", r.Key, "status", r.StatusCode, "duration", r.Duration}
+
+	if r.Err != nil {
+		fields = append(fields, "error", r.Err)
+	}
+
+	return
+}
+
+// A task that creates an instance from a JSON instance body.
+func (c *Client) createTask(ctx context.Context, category string, systemTypeName string, key string, body string) BatchTask {
+	return BatchTask{
+		Action: ActionCreate,
+		Type:   systemTypeName,
+		Key:    key,
+		Run: func() (statusCode int, err error) {
+			var name string
+			var properties map[string]interface{}
+
+			name, properties, err = decodeInstanceBody(body)
+
+			if err == nil {
+				_, statusCode, err = c.CreateWithStatus(ctx, category, systemTypeName, name, properties)
+			}
+
+			return
+		},
+	}
+}
+
+// A task that updates an instance from a JSON instance body.
+func (c *Client) updateTask(ctx context.Context, category string, systemTypeName string, key string, body string, id string) BatchTask {
+	return BatchTask{
+		Action: ActionUpdate,
+		Type:   systemTypeName,
+		Key:    key,
+		Run: func() (statusCode int, err error) {
+			var name string
+			var properties map[string]interface{}
+
+			name, properties, err = decodeInstanceBody(body)
+
+			if err == nil {
+				_, statusCode, err = c.UpdateWithStatus(ctx, category, systemTypeName, id, name, properties)
+			}
+
+			return
+		},
+	}
+}
+
+// A task that deletes an instance by id.
+func (c *Client) deleteTask(ctx context.Context, category string, systemTypeName string, key string, id string) BatchTask {
+	return BatchTask{
+		Action: ActionDelete,
+		Type:   systemTypeName,
+		Key:    key,
+		Run: func() (statusCode int, err error) {
+			return c.DeleteWithStatus(ctx, category, systemTypeName, id)
+		},
+	}
+}
+
+// A task that deletes an instance listed from CMS, following its delete link.
+func (c *Client) deleteInstanceTask(ctx context.Context, instance sdk.Instance) BatchTask {
+	return BatchTask{
+		Action: ActionDelete,
+		Type:   instance.Type,
+		Key:    instance.Id,
+		Run: func() (statusCode int, err error) {
+			return c.DeleteInstanceWithStatus(ctx, instance)
+		},
+	}
+}
+
+// A task that fails without sending a request, so items that can't be processed still appear in the results.
+func failedTask(action string, key string, err error) BatchTask {
+	return BatchTask{
+		Action: action,
+		Key:    key,
+		Run: func() (int, error) {
+			return 0, err
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package cms
+
+import (
+	sdk "ocp/sample/planets/pkg/cms"
+)
+
+// How the CLI connects to CMS: the SDK client options and how many batch requests are sent at once.
+type Options struct {
+	sdk.Options
+	// The maximum number of batch requests in flight at once, DefaultConcurrency when zero
+	Concurrency int
+	// The maximum number of instances printed by the info commands, every instance when zero.
+	// Batch, sync and plan operations always read every instance.
+	MaxItems int
+}
+
+// Runs the CLI's batch, sync and plan operations on top of the CMS SDK client.
+// Create one with NewClient and share it between operations.
+type Client struct {
+	*sdk.Client
+	concurrency int
+	pageSize    int
+	maxItems    int
+}
+
+// Creates a client from the options.
+func NewClient(opts Options) (client *Client, err error) {
+	var sdkClient *sdk.Client
+
+	if opts.Concurrency < 1 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	sdkClient, err = sdk.NewClient(opts.Options)
+
+	if err == nil {
+		client = &Client{Client: sdkClient, concurrency: opts.Concurrency, pageSize: opts.PageOptions.PageSize, maxItems: opts.MaxItems}
+	}
+
+	return
+}
@@ -0,0 +1,73 @@
+package cms
+
+import (
+	"context"
+	logutil "ocp/sample/planets/internal/util/log"
+	outpututil "ocp/sample/planets/internal/util/output"
+	sdk "ocp/sample/planets/pkg/cms"
+
+	"github.com/tidwall/gjson"
+)
+
+// Fetches the instances of a given category and type matching the list options from CMS and prints them to stdout.
+// The table columns are the id, type, name and the properties of the first instance unless --fields is given.
+func (c *Client) InstanceInfo(ctx context.Context, category string, systemTypeName string, listOpts sdk.ListOptions) (err error) {
+	return c.printInstances(ctx, category, systemTypeName, listOpts)
+}
+
+// Streams the instances of a type to a printer, page by page, so large collections are printed as they arrive.
+func (c *Client) printInstances(ctx context.Context, category string, systemTypeName string, listOpts sdk.ListOptions, defaultFields ...string) (err error) {
+	printer := outpututil.NewPrinter(defaultFields...)
+	pager := c.NewInstancePager(ctx, category, systemTypeName, sdk.PageOptions{PageSize: c.pageSize, MaxItems: c.maxItems}, listOpts)
+	instanceCount := 0
+
+	for err == nil && pager.Next() {
+		err = printer.Print(gjson.ParseBytes(pager.Instance().Raw))
+		instanceCount++
+	}
+
+	if err == nil {
+		err = pager.Err()
+	}
+
+	// Close even after a failure so structured output, like a JSON array, is always terminated.
+	if closeErr := printer.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil && instanceCount == 0 {
+		c.Logger.Log(logutil.INFO_LEVEL, "No instances found", "type", systemTypeName)
+	}
+
+	return
+}
+
+// Updates a single instance, reporting an HTTP failure as an error.
+func (c *Client) UpdateInstanceById(ctx context.Context, category string, systemTypeName string, body string, id string) (err error) {
+	return c.CheckResults(ActionUpdate, c.RunBatch([]BatchTask{c.updateTask(ctx, category, systemTypeName, id, body, id)}))
+}
+
+// Deletes a single instance by id, reporting an HTTP failure as an error.
+func (c *Client) DeleteInstanceById(ctx context.Context, category string, systemTypeName string, id string) (err error) {
+	return c.CheckResults(ActionDelete, c.RunBatch([]BatchTask{c.deleteTask(ctx, category, systemTypeName, id, id)}))
+}
+
+// Creates one instance per body in a JSON payload holding a single instance body or an array of them.
+func (c *Client) CreateInstances(ctx context.Context, category string, systemTypeName string, payload string) (results []BatchResult, err error) {
+	var bodies []string
+
+	bodies, err = InstanceBodiesFromJSON(payload)
+
+	if err == nil {
+		tasks := make([]BatchTask, len(bodies))
+
+		for i, body := range bodies {
+			tasks[i] = c.createTask(ctx, category, systemTypeName, gjson.Get(body, "name").String(), body)
+		}
+
+		results = c.RunBatch(tasks)
+		err = c.CheckResults(ActionCreate, results)
+	}
+
+	return
+}
@@ -0,0 +1,35 @@
+package cms
+
+import (
+	"context"
+	outpututil "ocp/sample/planets/internal/util/output"
+	sdk "ocp/sample/planets/pkg/cms"
+
+	"github.com/tidwall/gjson"
+)
+
+// Gets a single instance by id and prints it to stdout.
+func (c *Client) InstanceByIdInfo(ctx context.Context, category string, systemTypeName string, id string, defaultFields ...string) (err error) {
+	var instance sdk.Instance
+
+	instance, err = c.Get(ctx, category, systemTypeName, id)
+
+	if err == nil {
+		err = outpututil.PrintRecord(gjson.ParseBytes(instance.Raw), defaultFields...)
+	}
+
+	return
+}
+
+// Gets the single instance with the given name and prints it to stdout.
+func (c *Client) InstanceByNameInfo(ctx context.Context, category string, systemTypeName string, name string, defaultFields ...string) (err error) {
+	var instance sdk.Instance
+
+	instance, err = c.FindByName(ctx, category, systemTypeName, name)
+
+	if err == nil {
+		err = outpututil.PrintRecord(gjson.ParseBytes(instance.Raw), defaultFields...)
+	}
+
+	return
+}
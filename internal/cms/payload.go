@@ -0,0 +1,113 @@
+package cms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ocp/sample/planets/internal/model"
+	jsonutil "ocp/sample/planets/internal/util/json"
+	logutil "ocp/sample/planets/internal/util/log"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Builds an instance body from a name and an arbitrary JSON object of properties.
+func NewInstanceBodyJSON(name string, propertiesJSON string) (body string, err error) {
+	instanceBody := &InstanceBody{Name: name}
+
+	if len(propertiesJSON) > 0 {
+		if !gjson.Valid(propertiesJSON) || !gjson.Parse(propertiesJSON).IsObject() {
+			err = errors.New("Instance properties must be a JSON object")
+			logutil.LogError(err)
+		} else {
+			instanceBody.Properties = json.RawMessage(propertiesJSON)
+		}
+	}
+
+	if err == nil {
+		body, err = jsonutil.ToJSON(instanceBody)
+	}
+
+	return
+}
+
+// Splits a JSON payload holding either a single instance body or an array of
+// instance bodies into one JSON string per instance. Every body must have a name.
+func InstanceBodiesFromJSON(payload string) (bodies []string, err error) {
+	if !gjson.Valid(payload) {
+		err = errors.New("Instance payload is not valid JSON")
+		logutil.LogError(err)
+		return
+	}
+
+	parsed := gjson.Parse(payload)
+
+	if parsed.IsObject() {
+		bodies = append(bodies, parsed.Raw)
+	} else if parsed.IsArray() {
+		parsed.ForEach(func(_, value gjson.Result) bool {
+			bodies = append(bodies, value.Raw)
+			return true
+		})
+	} else {
+		err = errors.New("Instance payload must be a JSON object or an array of JSON objects")
+		logutil.LogError(err)
+	}
+
+	for i := 0; err == nil && i < len(bodies); i++ {
+		body := gjson.Parse(bodies[i])
+
+		if !body.IsObject() || len(body.Get("name").String()) == 0 {
+			err = fmt.Errorf("Instance payload item %d must be a JSON object with a name", i)
+			logutil.LogError(err)
+		}
+	}
+
+	return
+}
+
+// Builds an instance body for a flat data record using the attributes of a model type.
+// When requiredOnly is set only the type's required attributes are populated.
+func InstanceBodyFromRecord(t *model.Type, record gjson.Result, requiredOnly bool) (body string, err error) {
+	instanceBody := &InstanceBody{Name: record.Get("name").String()}
+
+	if requiredOnly {
+		instanceBody.Properties = t.RequiredProperties(record)
+	} else {
+		instanceBody.Properties = t.Properties(record)
+	}
+
+	return jsonutil.ToJSON(instanceBody)
+}
+
+// Reads the name and properties from a JSON instance body. Numbers are kept as written rather than converted to floats.
+func decodeInstanceBody(body string) (name string, properties map[string]interface{}, err error) {
+	var instanceBody struct {
+		Name       string                 `json:"name"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	if err = decoder.Decode(&instanceBody); err != nil {
+		err = fmt.Errorf("Invalid instance body: %s", err)
+		logutil.LogError(err)
+	}
+
+	return instanceBody.Name, instanceBody.Properties, err
+}
+
+// Gets the model type for a system type name from the project's model files.
+func modelType(systemTypeName string) (t *model.Type, err error) {
+	var m *model.Model
+
+	m, err = model.Cached()
+
+	if err == nil {
+		t, err = m.Type(systemTypeName)
+	}
+
+	return
+}
@@ -0,0 +1,326 @@
+package cms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	ioutil "ocp/sample/planets/internal/util/io"
+	jsonutil "ocp/sample/planets/internal/util/json"
+	logutil "ocp/sample/planets/internal/util/log"
+	sdk "ocp/sample/planets/pkg/cms"
+	"os"
+	"sort"
+	"time"
+)
+
+// A saved plan that can be applied later, provided CMS hasn't drifted since it was made. Every instance
+// of the type is in the plan, those left alone as unchanged entries, so any drift can be detected.
+type Plan struct {
+	Category       string          `json:"category"`
+	SystemTypeName string          `json:"type"`
+	Key            string          `json:"key"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Changes        []PlannedChange `json:"changes"`
+}
+
+// A change in a saved plan. The fingerprint records the state of the instance when the plan was made.
+type PlannedChange struct {
+	Action      string          `json:"action"`
+	Key         string          `json:"key"`
+	Id          string          `json:"id,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	Diff        []PropertyDiff  `json:"diff"`
+}
+
+// The old and new JSON values of a single instance property. Old is empty for additions and New for removals.
+type PropertyDiff struct {
+	Property string `json:"property"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+}
+
+// Works out what a sync would change and builds a plan without sending any mutating request.
+// Instances the sync leaves alone, including those missing from the data file, are kept as
+// unchanged entries holding only their id and fingerprint.
+func (c *Client) MakePlan(ctx context.Context, opts SyncOptions) (plan Plan, err error) {
+	var changes []Change
+	var instances []sdk.Instance
+
+	if len(opts.Key) == 0 {
+		opts.Key = DefaultSyncKey
+	}
+
+	changes, instances, err = c.reconcile(ctx, opts)
+
+	if err == nil {
+		plan = Plan{
+			Category:       opts.Category,
+			SystemTypeName: opts.SystemTypeName,
+			Key:            opts.Key,
+			CreatedAt:      time.Now().UTC(),
+		}
+
+		planned := make(map[string]bool)
+
+		for _, change := range changes {
+			if change.Action != ActionUnchanged {
+				plan.Changes = append(plan.Changes, plannedChange(change))
+				planned[change.Id] = true
+			}
+		}
+
+		for _, instance := range instances {
+			if !planned[instance.Id] {
+				plan.Changes = append(plan.Changes, PlannedChange{
+					Action:      ActionUnchanged,
+					Key:         instanceKey(instance, opts.Key),
+					Id:          instance.Id,
+					Fingerprint: fingerprint(instance),
+				})
+			}
+		}
+	}
+
+	return
+}
+
+func plannedChange(change Change) PlannedChange {
+	planned := PlannedChange{
+		Action: change.Action,
+		Key:    change.Key,
+		Id:     change.Id,
+		Diff:   propertyDiff(change.Body, change.Instance),
+	}
+
+	if len(change.Body) > 0 {
+		planned.Body = json.RawMessage(change.Body)
+	}
+
+	if change.Instance != nil {
+		planned.Fingerprint = fingerprint(*change.Instance)
+	}
+
+	return planned
+}
+
+// Compares the name and properties of an instance body with an instance, property by property.
+// An empty body means the instance is being removed.
+func propertyDiff(body string, instance *sdk.Instance) (diffs []PropertyDiff) {
+	var oldValues map[string]interface{}
+	var newValues map[string]interface{}
+
+	if instance != nil {
+		oldValues = instanceValues(instance.Name, instance.Properties)
+	}
+
+	if len(body) > 0 {
+		name, properties, _ := decodeInstanceBody(body)
+		newValues = instanceValues(name, properties)
+	}
+
+	names := make([]string, 0, len(oldValues)+len(newValues))
+
+	for name := range newValues {
+		names = append(names, name)
+	}
+
+	for name := range oldValues {
+		if _, ok := newValues[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldValue, hasOld := oldValues[name]
+		newValue, hasNew := newValues[name]
+
+		// Properties missing from the body are left untouched by an update rather than removed.
+		if newValues != nil && !hasNew {
+			continue
+		}
+
+		if hasOld != hasNew || !valuesEqual(oldValue, newValue) {
+			diffs = append(diffs, PropertyDiff{Property: name, Old: rawValue(oldValue, hasOld), New: rawValue(newValue, hasNew)})
+		}
+	}
+
+	return
+}
+
+// Flattens the name and properties of an instance into one map keyed by property name.
+func instanceValues(name string, properties map[string]interface{}) map[string]interface{} {
+	values := map[string]interface{}{"name": name}
+
+	for key, value := range properties {
+		values[key] = value
+	}
+
+	return values
+}
+
+// A value as JSON, or empty when there is no value.
+func rawValue(value interface{}, ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	raw, _ := json.Marshal(value)
+
+	return string(raw)
+}
+
+// A hash of an instance's name and properties used to detect drift between planning and applying.
+func fingerprint(instance sdk.Instance) string {
+	state, _ := json.Marshal(map[string]interface{}{
+		"name":       instance.Name,
+		"properties": normalise(instance.Properties),
+	})
+	sum := sha256.Sum256(state)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Prints a colorized diff of the plan to the console.
+func PrintPlan(plan Plan) {
+	var creates, updates, deletes int
+
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionCreate:
+			creates++
+			fmt.Println(logutil.Colorize(logutil.INFO_LEVEL, fmt.Sprintf("+ %s %s", plan.SystemTypeName, change.Key)))
+		case ActionUpdate:
+			updates++
+			fmt.Println(logutil.Colorize(logutil.WARN_LEVEL, fmt.Sprintf("~ %s %s (id: %s)", plan.SystemTypeName, change.Key, change.Id)))
+		case ActionDelete:
+			deletes++
+			fmt.Println(logutil.Colorize(logutil.ERROR_LEVEL, fmt.Sprintf("- %s %s (id: %s)", plan.SystemTypeName, change.Key, change.Id)))
+		}
+
+		for _, diff := range change.Diff {
+			switch {
+			case len(diff.Old) == 0:
+				fmt.Println(logutil.Colorize(logutil.INFO_LEVEL, fmt.Sprintf("    + %s: %s", diff.Property, diff.New)))
+			case len(diff.New) == 0:
+				fmt.Println(logutil.Colorize(logutil.ERROR_LEVEL, fmt.Sprintf("    - %s: %s", diff.Property, diff.Old)))
+			default:
+				fmt.Println(logutil.Colorize(logutil.WARN_LEVEL, fmt.Sprintf("    ~ %s: %s => %s", diff.Property, diff.Old, diff.New)))
+			}
+		}
+	}
+
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete.\n", creates, updates, deletes)
+}
+
+// Writes a plan to a JSON file so it can be applied later.
+func (c *Client) SavePlan(plan Plan, path string) (err error) {
+	var planJSON string
+
+	planJSON, err = jsonutil.ToJSON(plan)
+
+	if err == nil {
+		err = os.WriteFile(path, []byte(planJSON), 0644)
+	}
+
+	if err != nil {
+		c.Logger.LogError(err)
+	} else {
+		c.Logger.Log(logutil.INFO_LEVEL, "Plan saved", "path", path)
+	}
+
+	return
+}
+
+// Reads a plan saved by SavePlan.
+func (c *Client) LoadPlan(path string) (plan Plan, err error) {
+	var planJSON string
+
+	planJSON, err = ioutil.ReadFileAsString(path)
+
+	if err == nil {
+		err = json.Unmarshal([]byte(planJSON), &plan)
+	}
+
+	if err != nil {
+		c.Logger.LogError(err)
+	}
+
+	return
+}
+
+// Applies a saved plan. CMS is checked for drift first and nothing is changed if any instance
+// of the type has been created, changed or removed since the plan was made.
+func (c *Client) ApplyPlan(ctx context.Context, plan Plan) (summary SyncSummary, err error) {
+	var instances []sdk.Instance
+
+	instances, err = c.InstancesByType(ctx, plan.Category, plan.SystemTypeName)
+
+	if err == nil {
+		err = c.checkDrift(plan, instances)
+	}
+
+	if err == nil {
+		changes := make([]Change, len(plan.Changes))
+
+		for i, planned := range plan.Changes {
+			changes[i] = Change{Action: planned.Action, Key: planned.Key, Id: planned.Id, Body: string(planned.Body)}
+		}
+
+		summary, err = c.applyChanges(ctx, plan.Category, plan.SystemTypeName, changes)
+	}
+
+	return
+}
+
+// Compares every instance of the type with the fingerprints saved in the plan. An instance the plan never
+// saw, or a saved one that has changed or gone, is drift.
+func (c *Client) checkDrift(plan Plan, instances []sdk.Instance) (err error) {
+	byId := make(map[string]sdk.Instance)
+	planned := make(map[string]bool)
+	drifted := 0
+
+	for _, instance := range instances {
+		byId[instance.Id] = instance
+	}
+
+	for _, change := range plan.Changes {
+		var message string
+
+		if change.Action == ActionCreate {
+			continue
+		}
+
+		planned[change.Id] = true
+
+		if instance, exists := byId[change.Id]; !exists {
+			message = "Instance has been deleted since the plan was made"
+		} else if fingerprint(instance) != change.Fingerprint {
+			message = "Instance has changed since the plan was made"
+		}
+
+		if len(message) > 0 {
+			drifted++
+			c.Logger.Log(logutil.ERROR_LEVEL, message, "id", change.Id, "key", change.Key)
+		}
+	}
+
+	for _, instance := range instances {
+		if !planned[instance.Id] {
+			drifted++
+			c.Logger.Log(logutil.ERROR_LEVEL, "Instance has been created since the plan was made", "id", instance.Id, "key", instanceKey(instance, plan.Key))
+		}
+	}
+
+	if drifted > 0 {
+		err = errors.New("CMS has drifted since the plan was made, create a new plan before applying")
+		c.Logger.LogError(err)
+	}
+
+	return
+}
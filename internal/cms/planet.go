@@ -1,11 +1,11 @@
 package cms
 
 import (
-	"fmt"
+	"context"
 	"ocp/sample/planets/internal/config"
+	"ocp/sample/planets/internal/model"
 	ioutil "ocp/sample/planets/internal/util/io"
-	jsonutil "ocp/sample/planets/internal/util/json"
-	logutil "ocp/sample/planets/internal/util/log"
+	sdk "ocp/sample/planets/pkg/cms"
 
 	"github.com/tidwall/gjson"
 )
@@ -15,131 +15,138 @@ const (
 	PlanetCategory = "object"
 )
 
-// Model for the Planet type CMS properties
-type PlanetProps struct {
-	Diameter        int64   `json:"diameter"`
-	LengthOfDay     float64 `json:"length_of_day"`
-	NumberOfMoons   *int64  `json:"number_of_moons,integer,omitempty"`
-	MeanTemperature *int64  `json:"mean_temperature,integer,omitempty"`
-}
+// The columns printed for planets unless --fields is given
+var planetFields = []string{"id", "name", "diameter", "length_of_day", "number_of_moons", "mean_temperature"}
 
 // Reads in planet data from the json sample data and creates one instance per object
-// Deliberately doesn't populate the "number_of_moons" and "mean_temperature" CMS attributes.
-func CreatePlanets() (err error) {
+// Only the required attributes of the planet model are populated, so the optional
+// "number_of_moons" and "mean_temperature" CMS attributes are deliberately left unset.
+func (c *Client) CreatePlanets(ctx context.Context) (results []BatchResult, err error) {
 	var planetJSON string
+	var planetType *model.Type
+	var tasks []BatchTask
 
-	planetJSON, err = readPlanetData()
+	planetType, planetJSON, err = readPlanetData()
 
 	if err == nil {
 		gjson.Parse(planetJSON).ForEach(func(_, value gjson.Result) bool {
-			instanceBody := &InstanceBody{
-				Name: value.Get("name").String(),
-				Properties: PlanetProps{
-					Diameter:    value.Get("diameter").Int(),
-					LengthOfDay: value.Get("length_of_day").Float(),
-				},
-			}
 			var postBody string
-			postBody, err = jsonutil.ToJSON(instanceBody)
+			postBody, err = InstanceBodyFromRecord(planetType, value, true)
 
 			if err == nil {
-				CreateInstance(PlanetCategory, PlanetType, postBody)
+				tasks = append(tasks, c.createTask(ctx, PlanetCategory, PlanetType, value.Get("name").String(), postBody))
 			}
 
-			return true
+			return err == nil
 		})
 	}
 
+	if err == nil {
+		results = c.RunBatch(tasks)
+		err = c.CheckResults(ActionCreate, results)
+	}
+
 	return
 }
 
 // Fetches the existing planets instance from CMS. Loops through and performs an update on each instance.
-// CMS type attributes "number_of_moons" and "mean_temperature" that weren't previously set are set now.
-func UpdatePlanets() (err error) {
-	var id string
+// Every attribute of the planet model is populated, so "number_of_moons" and "mean_temperature"
+// that weren't previously set are set now.
+func (c *Client) UpdatePlanets(ctx context.Context) (results []BatchResult, err error) {
 	var planetJSON string
-	var instances gjson.Result
+	var instances []sdk.Instance
+	var planetType *model.Type
+	var tasks []BatchTask
 
-	planetJSON, err = readPlanetData()
+	planetType, planetJSON, err = readPlanetData()
 
 	if err == nil {
-		_, instances, err = InstancesByType(PlanetCategory, PlanetType)
+		instances, err = c.InstancesByType(ctx, PlanetCategory, PlanetType)
 	}
 
 	if err == nil {
 		gjson.Parse(planetJSON).ForEach(func(_, value gjson.Result) bool {
+			var id string
 			name := value.Get("name").String()
-			numMoons := value.Get("number_of_moons").Int()
-			meanTemp := value.Get("mean_temperature").Int()
-			instanceBody := &InstanceBody{
-				Name: name,
-				Properties: PlanetProps{
-					Diameter:        value.Get("diameter").Int(),
-					LengthOfDay:     value.Get("length_of_day").Float(),
-					NumberOfMoons:   &numMoons,
-					MeanTemperature: &meanTemp,
-				},
-			}
 
-			instances.ForEach(func(_, instance gjson.Result) bool {
-				if instance.Get("name").String() == name {
-					id = instance.Get("id").String()
-					return false
-				} else {
-					return true
+			for _, instance := range instances {
+				if instance.Name == name {
+					id = instance.Id
+					break
 				}
-			})
+			}
 
 			var postBody string
-			postBody, err = jsonutil.ToJSON(instanceBody)
+			postBody, err = InstanceBodyFromRecord(planetType, value, false)
 
-			if err == nil {
-				UpdateInstance(PlanetCategory, PlanetType, postBody, id)
+			if err == nil && len(id) == 0 {
+				tasks = append(tasks, failedTask(ActionUpdate, name, &sdk.NotFoundError{SystemTypeName: PlanetType, Name: name}))
+			} else if err == nil {
+				tasks = append(tasks, c.updateTask(ctx, PlanetCategory, PlanetType, name, postBody, id))
 			}
 
-			return true
+			return err == nil
 		})
 	}
+
+	if err == nil {
+		results = c.RunBatch(tasks)
+		err = c.CheckResults(ActionUpdate, results)
+	}
+
 	return
 }
 
 // Deletes all planet instances.
-func DeletePlanets() (err error) {
-	return DeleteInstancesByType(PlanetCategory, PlanetType)
+func (c *Client) DeletePlanets(ctx context.Context) (results []BatchResult, err error) {
+	return c.DeleteInstancesByType(ctx, PlanetCategory, PlanetType)
+}
+
+// Fetches the planet instances matching the list options from CMS and prints their diameter, length of day, moons and temperature to stdout.
+// Instances are streamed page by page so large collections are printed as they arrive.
+func (c *Client) PlanetInfo(ctx context.Context, listOpts sdk.ListOptions) (err error) {
+	return c.printInstances(ctx, PlanetCategory, PlanetType, listOpts, planetFields...)
+}
+
+// Gets a single planet by id and prints it to stdout.
+func (c *Client) PlanetByIdInfo(ctx context.Context, id string) (err error) {
+	return c.InstanceByIdInfo(ctx, PlanetCategory, PlanetType, id, planetFields...)
+}
+
+// Gets the single planet with the given name and prints it to stdout.
+func (c *Client) PlanetByNameInfo(ctx context.Context, name string) (err error) {
+	return c.InstanceByNameInfo(ctx, PlanetCategory, PlanetType, name, planetFields...)
 }
 
-// Fetches all planet instances from CMS and logs out some basic information to the console.
-func PlanetInfo() (err error) {
-	_, instances, err := InstancesByType(PlanetCategory, PlanetType)
+// Reads planet JSON data from the sample file and validates it against the planet model
+func readPlanetData() (planetType *model.Type, planetJSON string, err error) {
+	var sampleDataPath string
+
+	sampleDataPath, err = config.SampleDataPath()
 
 	if err == nil {
-		instances.ForEach(func(_, value gjson.Result) bool {
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Id: %s", value.Get("id").String()))
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Type: %s", value.Get("type").String()))
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Name: %s", value.Get("name").String()))
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Diameter (km): %s", value.Get("properties.diameter").String()))
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Length of day (hours): %s", value.Get("properties.length_of_day").String()))
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Number of moons: %s", value.Get("properties.number_of_moons").String()))
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("Mean temperature (°C): %s", value.Get("properties.mean_temperature").String()))
-			logutil.Log(logutil.INFO_LEVEL, "-----------------------------------------------------")
-			return true
-		})
+		planetJSON, err = ioutil.ReadFileAsString(sampleDataPath)
+	}
 
-		if len(instances.Array()) == 0 {
-			logutil.Log(logutil.INFO_LEVEL, fmt.Sprintf("No instances of type %s found", PlanetType))
-		}
+	if err == nil {
+		planetType, err = modelType(PlanetType)
 	}
+
+	if err == nil {
+		err = validateData(planetType, sampleDataPath, planetJSON)
+	}
+
 	return
 }
 
-// Reads planet JSON data from the sample file
-func readPlanetData() (planetJSON string, err error) {
+// Validates the sample planet data against the planet model
+func ValidatePlanets() (err error) {
 	var sampleDataPath string
 
 	sampleDataPath, err = config.SampleDataPath()
 
 	if err == nil {
-		planetJSON, err = ioutil.ReadFileAsString(sampleDataPath)
+		err = ValidateDataFile(PlanetType, sampleDataPath)
 	}
 
 	return
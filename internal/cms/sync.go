@@ -0,0 +1,284 @@
+package cms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ocp/sample/planets/internal/model"
+	ioutil "ocp/sample/planets/internal/util/io"
+	logutil "ocp/sample/planets/internal/util/log"
+	sdk "ocp/sample/planets/pkg/cms"
+	"reflect"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DefaultSyncKey = "name"
+
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionUnchanged = "unchanged"
+	ActionSync      = "sync"
+)
+
+// Options for reconciling a data file with the instances in CMS
+type SyncOptions struct {
+	Category       string
+	SystemTypeName string
+	DataPath       string
+	Key            string
+	Prune          bool
+}
+
+// Counts of what a sync did
+type SyncSummary struct {
+	Created   int
+	Updated   int
+	Deleted   int
+	Unchanged int
+	Failed    int
+}
+
+// A single change needed to bring CMS in line with the data file
+type Change struct {
+	Action   string
+	Key      string
+	Id       string
+	Record   gjson.Result
+	Instance *sdk.Instance
+	Body     string
+}
+
+func (s SyncSummary) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d deleted, %d unchanged, %d failed", s.Created, s.Updated, s.Deleted, s.Unchanged, s.Failed)
+}
+
+// Reconciles a data file with CMS: records without a matching instance are created, matching
+// instances whose properties differ are updated and, when pruning, instances missing from the
+// data file are deleted. Records and instances are matched on the configured key.
+func (c *Client) Sync(ctx context.Context, opts SyncOptions) (summary SyncSummary, err error) {
+	var changes []Change
+
+	changes, err = c.Reconcile(ctx, opts)
+
+	if err == nil {
+		summary, err = c.applyChanges(ctx, opts.Category, opts.SystemTypeName, changes)
+	}
+
+	return
+}
+
+// Sends the create, update and delete requests for a set of changes, carrying on past failures.
+func (c *Client) applyChanges(ctx context.Context, category string, systemTypeName string, changes []Change) (summary SyncSummary, err error) {
+	var tasks []BatchTask
+
+	for _, change := range changes {
+		switch change.Action {
+		case ActionCreate:
+			tasks = append(tasks, c.createTask(ctx, category, systemTypeName, change.Key, change.Body))
+		case ActionUpdate:
+			tasks = append(tasks, c.updateTask(ctx, category, systemTypeName, change.Key, change.Body, change.Id))
+		case ActionDelete:
+			tasks = append(tasks, c.deleteTask(ctx, category, systemTypeName, change.Key, change.Id))
+		case ActionUnchanged:
+			summary.Unchanged++
+		}
+	}
+
+	results := c.RunBatch(tasks)
+
+	for _, result := range results {
+		summary.count(result)
+	}
+
+	if len(changes) > 0 {
+		c.Logger.Log(logutil.INFO_LEVEL, "Changes finished", append([]any{"type", systemTypeName}, summary.fields()...)...)
+	} else {
+		c.Logger.Log(logutil.INFO_LEVEL, "No changes needed", "type", systemTypeName)
+	}
+
+	err = c.CheckResults(ActionSync, results)
+
+	return
+}
+
+// Key/value pairs of the counts for structured logs
+func (s SyncSummary) fields() []any {
+	return []any{"created", s.Created, "updated", s.Updated, "deleted", s.Deleted, "unchanged", s.Unchanged, "failed", s.Failed}
+}
+
+func (s *SyncSummary) count(result BatchResult) {
+	if !result.Success() {
+		s.Failed++
+		return
+	}
+
+	switch result.Action {
+	case ActionCreate:
+		s.Created++
+	case ActionUpdate:
+		s.Updated++
+	case ActionDelete:
+		s.Deleted++
+	}
+}
+
+// Works out the changes needed to bring CMS in line with the data file without sending any mutating request.
+func (c *Client) Reconcile(ctx context.Context, opts SyncOptions) (changes []Change, err error) {
+	changes, _, err = c.reconcile(ctx, opts)
+
+	return
+}
+
+// Works out the changes for a sync, also returning every instance of the type they were worked out from.
+func (c *Client) reconcile(ctx context.Context, opts SyncOptions) (changes []Change, instances []sdk.Instance, err error) {
+	var dataJSON string
+	var t *model.Type
+
+	if len(opts.Key) == 0 {
+		opts.Key = DefaultSyncKey
+	}
+
+	dataJSON, err = ioutil.ReadFileAsString(opts.DataPath)
+
+	if err == nil {
+		t, err = modelType(opts.SystemTypeName)
+	}
+
+	if err == nil {
+		err = validateData(t, opts.DataPath, dataJSON)
+	}
+
+	if err == nil {
+		instances, err = c.InstancesByType(ctx, opts.Category, opts.SystemTypeName)
+	}
+
+	if err == nil {
+		changes, err = c.diffRecords(t, opts, gjson.Parse(dataJSON), instances)
+	}
+
+	return
+}
+
+// Compares data records with CMS instances, matching them on the sync key.
+func (c *Client) diffRecords(t *model.Type, opts SyncOptions, records gjson.Result, instances []sdk.Instance) (changes []Change, err error) {
+	existing := make(map[string]sdk.Instance)
+	seen := make(map[string]bool)
+
+	for _, instance := range instances {
+		key := instanceKey(instance, opts.Key)
+
+		if _, duplicate := existing[key]; duplicate {
+			c.Logger.Log(logutil.WARN_LEVEL, "Duplicate instances for sync key, only the first will be synced", "key", opts.Key, "value", key, "id", instance.Id)
+		} else {
+			existing[key] = instance
+		}
+	}
+
+	records.ForEach(func(_, record gjson.Result) bool {
+		change := Change{Key: record.Get(opts.Key).String(), Record: record}
+
+		if seen[change.Key] {
+			err = fmt.Errorf("More than one record in %s has %s %q", opts.DataPath, opts.Key, change.Key)
+			c.Logger.LogError(err)
+			return false
+		}
+
+		seen[change.Key] = true
+		change.Body, err = InstanceBodyFromRecord(t, record, false)
+
+		if err != nil {
+			return false
+		}
+
+		if instance, ok := existing[change.Key]; !ok {
+			change.Action = ActionCreate
+		} else {
+			change.Instance = &instance
+			change.Id = instance.Id
+
+			if instanceChanged(change.Body, instance) {
+				change.Action = ActionUpdate
+			} else {
+				change.Action = ActionUnchanged
+			}
+		}
+
+		changes = append(changes, change)
+		return true
+	})
+
+	if err == nil && opts.Prune {
+		for i, instance := range instances {
+			key := instanceKey(instance, opts.Key)
+
+			if !seen[key] {
+				changes = append(changes, Change{Action: ActionDelete, Key: key, Id: instance.Id, Instance: &instances[i]})
+			}
+		}
+	}
+
+	return
+}
+
+// Gets the value an instance is matched on. The key is either the instance name or one of its properties.
+func instanceKey(instance sdk.Instance, key string) string {
+	if key == DefaultSyncKey {
+		return instance.Name
+	}
+
+	return valueString(instance.Properties[key])
+}
+
+// A property value as text: strings as they are, numbers without an exponent and anything else as JSON.
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	raw, _ := json.Marshal(value)
+
+	return string(raw)
+}
+
+// Checks whether the name or any property in the instance body differs from the instance in CMS.
+// Instance bodies are built from validated records, so they always decode.
+func instanceChanged(body string, instance sdk.Instance) bool {
+	name, properties, _ := decodeInstanceBody(body)
+
+	if name != instance.Name {
+		return true
+	}
+
+	for key, value := range properties {
+		if current, ok := instance.Properties[key]; !ok || !valuesEqual(value, current) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compares two JSON values, treating numbers such as 24 and 24.0 as equal.
+func valuesEqual(a interface{}, b interface{}) bool {
+	return reflect.DeepEqual(normalise(a), normalise(b))
+}
+
+// Round trips a value through JSON so numbers of any type compare as float64.
+func normalise(value interface{}) (normalised interface{}) {
+	raw, err := json.Marshal(value)
+
+	if err != nil || json.Unmarshal(raw, &normalised) != nil {
+		return value
+	}
+
+	return
+}
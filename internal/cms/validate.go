@@ -0,0 +1,54 @@
+package cms
+
+import (
+	"ocp/sample/planets/internal/model"
+	ioutil "ocp/sample/planets/internal/util/io"
+	logutil "ocp/sample/planets/internal/util/log"
+
+	"github.com/tidwall/gjson"
+)
+
+// Validates the records in a JSON data file against the model of a given type.
+// Every violation is logged before the error is returned.
+func ValidateDataFile(systemTypeName string, path string) (err error) {
+	var dataJSON string
+	var t *model.Type
+
+	dataJSON, err = ioutil.ReadFileAsString(path)
+
+	if err == nil {
+		t, err = modelType(systemTypeName)
+	}
+
+	if err == nil {
+		err = validateData(t, path, dataJSON)
+	}
+
+	if err == nil {
+		logutil.Log(logutil.INFO_LEVEL, "All records are valid", "path", path, "type", systemTypeName)
+	}
+
+	return
+}
+
+// Checks data records against a model type so bad rows are caught before any HTTP call is made.
+func validateData(t *model.Type, path string, dataJSON string) (err error) {
+	var violations []model.Violation
+
+	if !gjson.Valid(dataJSON) {
+		violations = []model.Violation{{File: path, Message: "data is not valid JSON"}}
+	} else {
+		violations = t.ValidateRecords(path, gjson.Parse(dataJSON))
+	}
+
+	for _, violation := range violations {
+		logutil.Log(logutil.ERROR_LEVEL, "Invalid data", "file", violation.File, "record", violation.Record, "attribute", violation.Attribute, "problem", violation.Message)
+	}
+
+	if len(violations) > 0 {
+		err = &model.ValidationError{Violations: violations}
+		logutil.LogError(err)
+	}
+
+	return
+}
@@ -1,12 +1,16 @@
-// The config package provides a central place to get information from the environment
+// The config package provides a central place to get information from the environment,
+// command line flags and the named profiles in the config file
 package config
 
 import (
-	"errors"
 	"fmt"
 	"net/url"
+	authutil "ocp/sample/planets/internal/util/auth"
 	logutil "ocp/sample/planets/internal/util/log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -15,16 +19,51 @@ const (
 	VAR_CONF_CLIENT_ID   = "CMS_DEMO_CONF_CLIENT_ID"
 	VAR_CLIENT_SECRET    = "CMS_DEMO_CLIENT_SECRET"
 	VAR_SAMPLE_DATA_PATH = "CMS_DEMO_SAMPLE_DATA_PATH"
+	VAR_GRANT_TYPE       = "CMS_DEMO_GRANT_TYPE"
+	VAR_USERNAME         = "CMS_DEMO_USERNAME"
+	VAR_PASSWORD         = "CMS_DEMO_PASSWORD"
+	VAR_REFRESH_TOKEN    = "CMS_DEMO_REFRESH_TOKEN"
+	VAR_PROJECT_PATH     = "CMS_DEMO_PROJECT_PATH"
+	VAR_RATE_LIMIT       = "CMS_DEMO_RATE_LIMIT"
+	VAR_TOKEN_CACHE      = "CMS_DEMO_TOKEN_CACHE"
+	VAR_TOKEN_CACHE_DIR  = "CMS_DEMO_TOKEN_CACHE_DIR"
+	VAR_REDACT_PATHS     = "CMS_DEMO_REDACT_PATHS"
+	VAR_LOG_LEVEL        = "CMS_DEMO_LOG_LEVEL"
+	VAR_LOG_FORMAT       = "CMS_DEMO_LOG_FORMAT"
+	VAR_LOG_FILE         = "CMS_DEMO_LOG_FILE"
+
+	DEFAULT_PROJECT_PATH = ".otproject"
+
+	SECRET_ENV_PREFIX = "env:"
+
+	GRANT_CLIENT_CREDENTIALS = authutil.GRANT_CLIENT_CREDENTIALS
+	GRANT_PASSWORD           = authutil.GRANT_PASSWORD
+	GRANT_REFRESH_TOKEN      = authutil.GRANT_REFRESH_TOKEN
 )
 
+// An error caused by missing or invalid configuration
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Wraps a configuration problem so callers can tell it apart from other failures.
+func newError(format string, a ...any) error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
 // The base url for the OCP environment
 func BaseUrl() (baseUrl string, err error) {
-	baseUrl, err = envVar(VAR_BASE_URL)
+	baseUrl, err = setting(VAR_BASE_URL)
 
 	if err == nil {
 		_, err = url.ParseRequestURI(baseUrl)
 		if err != nil {
-			logutil.Log(logutil.ERROR_LEVEL, "CMS_DEMO_BASE_URL environment variable is not a valid url")
+			err = newError("%s is not a valid url", VAR_BASE_URL)
+			logutil.LogError(err)
 		}
 	}
 
@@ -46,38 +85,177 @@ func CMSHost() (cmsHost string, err error) {
 
 // The Tenant ID for the OCP environment
 func TenantId() (tenantId string, err error) {
-	return envVar(VAR_TENANT_ID)
+	return setting(VAR_TENANT_ID)
 }
 
 // The Confidential Client ID for the OCP environment
 func ConfClientId() (confClientId string, err error) {
-	return envVar(VAR_CONF_CLIENT_ID)
+	return setting(VAR_CONF_CLIENT_ID)
 }
 
-// The Client Secret for the OCP environment
+// The Client Secret for the OCP environment. The value may be a reference such as env:NAME,
+// file:PATH, cmd:COMMAND or store:NAME, in which case the secret is read from where it points to.
 func ClientSecret() (clientSecret string, err error) {
-	return envVar(VAR_CLIENT_SECRET)
+	clientSecret, err = setting(VAR_CLIENT_SECRET)
+
+	if err == nil {
+		clientSecret, err = resolveSecret(clientSecret)
+	}
+
+	return
+}
+
+// The OAuth grant type used to fetch access tokens, defaulting to client_credentials
+func GrantType() (grantType string, err error) {
+	grantType, err = setting(VAR_GRANT_TYPE)
+	if err == nil && grantType != GRANT_CLIENT_CREDENTIALS && grantType != GRANT_PASSWORD && grantType != GRANT_REFRESH_TOKEN {
+		err = newError("%s must be one of %s, %s or %s.", VAR_GRANT_TYPE, GRANT_CLIENT_CREDENTIALS, GRANT_PASSWORD, GRANT_REFRESH_TOKEN)
+		logutil.LogError(err)
+	}
+	return
+}
+
+// The username for the password grant. Empty when not configured so the user can be prompted instead.
+func Username() (username string, err error) {
+	var s Setting
+	s, err = lookup(VAR_USERNAME)
+	return s.Value, err
+}
+
+// The password for the password grant, which may be a secret reference such as env:NAME.
+// Empty when not configured so the user can be prompted instead.
+func Password() (password string, err error) {
+	return optionalSecret(VAR_PASSWORD)
+}
+
+// The refresh token used to start the refresh_token grant, which may be a secret reference such as env:NAME
+func RefreshToken() (refreshToken string, err error) {
+	refreshToken, err = setting(VAR_REFRESH_TOKEN)
+
+	if err == nil {
+		refreshToken, err = resolveSecret(refreshToken)
+	}
+
+	return
 }
 
 // The path to the sample planet data
 func SampleDataPath() (sampleDataPath string, err error) {
-	sampleDataPath, err = envVar(VAR_SAMPLE_DATA_PATH)
+	sampleDataPath, err = setting(VAR_SAMPLE_DATA_PATH)
 	if err == nil {
 		_, err = os.Stat(sampleDataPath)
 		if err != nil {
-			logutil.Log(logutil.ERROR_LEVEL, fmt.Sprintf("Sample data file is not present at %s", sampleDataPath))
+			err = newError("Sample data file is not present at %s", sampleDataPath)
+			logutil.LogError(err)
+		}
+	}
+	return
+}
+
+// The path to the OpenText Cloud Developer Tools project file listing the model folders
+func ProjectPath() (projectPath string, err error) {
+	projectPath = envVarOrDefault(VAR_PROJECT_PATH, DEFAULT_PROJECT_PATH)
+	_, err = os.Stat(projectPath)
+	if err != nil {
+		err = newError("Project file is not present at %s", projectPath)
+		logutil.LogError(err)
+	}
+	return
+}
+
+// The maximum number of requests per second sent to OCP. Zero disables client-side rate limiting.
+func RateLimit(defaultRateLimit float64) (rateLimit float64, err error) {
+	rateLimit = defaultRateLimit
+	val := os.Getenv(VAR_RATE_LIMIT)
+	if len(val) > 0 {
+		rateLimit, err = strconv.ParseFloat(val, 64)
+		if err != nil || rateLimit < 0 {
+			err = newError("%s environment variable must be a non-negative number.", VAR_RATE_LIMIT)
+			logutil.LogError(err)
+		}
+	}
+	return
+}
+
+// Whether access tokens should be cached on disk and shared between runs
+func TokenCacheEnabled() (enabled bool) {
+	enabled, _ = strconv.ParseBool(os.Getenv(VAR_TOKEN_CACHE))
+	return
+}
+
+// The directory access tokens are cached in, defaulting to a planets folder in the user's cache directory
+func TokenCacheDir() (tokenCacheDir string, err error) {
+	tokenCacheDir = os.Getenv(VAR_TOKEN_CACHE_DIR)
+	if len(tokenCacheDir) == 0 {
+		tokenCacheDir, err = os.UserCacheDir()
+		if err == nil {
+			tokenCacheDir = filepath.Join(tokenCacheDir, "planets", "tokens")
+		} else {
+			err = newError("Unable to find a directory for the token cache, set %s instead.", VAR_TOKEN_CACHE_DIR)
+			logutil.LogError(err)
 		}
 	}
 	return
 }
 
-// Gets an environment variable and returns an error if no value is set.
-func envVar(key string) (val string, err error) {
+// Comma separated JSON paths whose values are masked in log output, in addition to the built in secrets
+func RedactPaths() (paths []string) {
+	for _, path := range strings.Split(os.Getenv(VAR_REDACT_PATHS), ",") {
+		if path = strings.TrimSpace(path); len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// The least severe level logged: debug, info, warn or error
+func LogLevel() (logLevel string) {
+	return envVarOrDefault(VAR_LOG_LEVEL, "info")
+}
+
+// The log format: text or json
+func LogFormat() (logFormat string) {
+	return envVarOrDefault(VAR_LOG_FORMAT, "text")
+}
+
+// The file logs are appended to. Empty when logs go to stderr.
+func LogFile() (logFile string) {
+	return os.Getenv(VAR_LOG_FILE)
+}
+
+// Gets an optional environment variable, falling back to a default when no value is set.
+func envVarOrDefault(key string, defaultVal string) (val string) {
 	val = os.Getenv(key)
 	if len(val) == 0 {
-		errStr := fmt.Sprintf("%s environment variable is missing.", key)
-		err = errors.New(errStr)
-		logutil.Log(logutil.ERROR_LEVEL, errStr)
+		val = defaultVal
+	}
+	return
+}
+
+// Gets a setting from the flags, environment, active profile or defaults and returns an error if no value is set.
+func setting(key string) (val string, err error) {
+	var s Setting
+
+	s, err = lookup(key)
+	val = s.Value
+
+	if err == nil && len(val) == 0 {
+		err = newError("%s environment variable is missing.", key)
+		logutil.LogError(err)
 	}
+
+	return
+}
+
+// Gets an optional secret setting, resolving any secret reference.
+func optionalSecret(key string) (secret string, err error) {
+	var s Setting
+
+	s, err = lookup(key)
+
+	if err == nil {
+		secret, err = resolveSecret(s.Value)
+	}
+
 	return
 }
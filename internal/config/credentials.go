@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	authutil "ocp/sample/planets/internal/util/auth"
+	logutil "ocp/sample/planets/internal/util/log"
+	"strings"
+)
+
+// Gets the authentication host from the environment.
+func AuthHost() (authHost string, err error) {
+	var baseUrl string
+
+	baseUrl, err = BaseUrl()
+
+	return fmt.Sprintf("%s/tenants", baseUrl), err
+}
+
+// Gets the authentication url from the environment.
+func AuthUrl() (url string, err error) {
+	var authHost string
+	var tenantId string
+
+	authHost, err = AuthHost()
+
+	if err == nil {
+		tenantId, err = TenantId()
+	}
+
+	if err == nil {
+		return fmt.Sprintf("%s/%s/oauth2/token", authHost, tenantId), err
+	}
+
+	return
+}
+
+// Reads the credentials from the flags, environment and profile.
+func Credentials() (creds authutil.Credentials, err error) {
+	creds.AuthUrl, err = AuthUrl()
+
+	if err == nil {
+		creds.ClientId, err = ConfClientId()
+	}
+
+	if err == nil {
+		creds.ClientSecret, err = ClientSecret()
+	}
+
+	if err == nil {
+		creds.GrantType, err = GrantType()
+	}
+
+	if err == nil && creds.GrantType == GRANT_PASSWORD {
+		creds.Username, err = Username()
+
+		if err == nil {
+			creds.Password, err = Password()
+		}
+	}
+
+	if err == nil && creds.GrantType == GRANT_REFRESH_TOKEN {
+		creds.RefreshToken, err = RefreshToken()
+	}
+
+	if err == nil && strings.Contains(creds.AuthUrl+creds.ClientId+creds.ClientSecret, "replace") {
+		err = &Error{Message: "You need to add your tenant id, client id and client secret to your environment."}
+		logutil.LogError(err)
+	}
+
+	return
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	VAR_CONFIG_FILE = "CMS_DEMO_CONFIG_FILE"
+	VAR_PROFILE     = "CMS_DEMO_PROFILE"
+
+	SOURCE_FLAG    = "flag"
+	SOURCE_ENV     = "env"
+	SOURCE_PROFILE = "profile"
+	SOURCE_DEFAULT = "default"
+	SOURCE_UNSET   = "unset"
+
+	REDACTED = logutil.REDACTED
+)
+
+// A named set of connection settings for one tenant and environment
+type Profile struct {
+	BaseUrl        string `json:"base_url,omitempty"`
+	TenantId       string `json:"tenant_id,omitempty"`
+	ConfClientId   string `json:"conf_client_id,omitempty"`
+	ClientSecret   string `json:"client_secret,omitempty"`
+	SampleDataPath string `json:"sample_data_path,omitempty"`
+	GrantType      string `json:"grant_type,omitempty"`
+	Username       string `json:"username,omitempty"`
+	Password       string `json:"password,omitempty"`
+	RefreshToken   string `json:"refresh_token,omitempty"`
+}
+
+// The config file holding every profile and the one used when none is chosen
+type File struct {
+	CurrentProfile string             `json:"current_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+// A resolved setting and where its value came from
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Values used when a setting isn't given anywhere else
+var defaults = map[string]string{
+	VAR_SAMPLE_DATA_PATH: "data/planet-data.json",
+	VAR_GRANT_TYPE:       GRANT_CLIENT_CREDENTIALS,
+}
+
+// Settings whose values are redacted whenever they are shown
+var secretKeys = map[string]bool{
+	VAR_CLIENT_SECRET: true,
+	VAR_PASSWORD:      true,
+	VAR_REFRESH_TOKEN: true,
+}
+
+// Values given on the command line, which take precedence over everything else
+var overrides = make(map[string]string)
+
+var profileName string
+var configFile *File
+var configMutex sync.Mutex
+
+// Sets a value from a command line flag. Empty values are ignored.
+func SetOverride(key string, val string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	if len(val) > 0 {
+		overrides[key] = val
+	}
+}
+
+// Chooses the profile to use, taking precedence over the environment and the config file.
+func SetProfile(name string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	profileName = name
+}
+
+// The path to the config file holding the profiles
+func ConfigFilePath() (path string, err error) {
+	path = os.Getenv(VAR_CONFIG_FILE)
+	if len(path) == 0 {
+		path, err = os.UserConfigDir()
+		if err == nil {
+			path = filepath.Join(path, "planets", "config.json")
+		} else {
+			err = newError("Unable to find a directory for the config file, set %s instead.", VAR_CONFIG_FILE)
+			logutil.LogError(err)
+		}
+	}
+	return
+}
+
+// Reads the config file. A missing file is treated as one with no profiles.
+func LoadFile() (file *File, err error) {
+	var path string
+	var contents []byte
+
+	path, err = ConfigFilePath()
+
+	if err == nil {
+		contents, err = os.ReadFile(path)
+	}
+
+	file = &File{Profiles: make(map[string]Profile)}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	} else if err == nil {
+		err = json.Unmarshal(contents, file)
+
+		if err != nil {
+			err = newError("Config file %s is not valid: %s", path, err)
+			logutil.LogError(err)
+		}
+	}
+
+	if file.Profiles == nil {
+		file.Profiles = make(map[string]Profile)
+	}
+
+	return
+}
+
+// Writes the config file, readable only by the current user as profiles may hold secrets.
+func SaveFile(file *File) (err error) {
+	var path string
+	var contents []byte
+
+	path, err = ConfigFilePath()
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+
+	if err == nil {
+		contents, err = json.MarshalIndent(file, "", "  ")
+	}
+
+	if err == nil {
+		err = os.WriteFile(path, contents, 0600)
+	}
+
+	if err != nil {
+		logutil.LogError(err)
+	}
+
+	configMutex.Lock()
+	configFile = nil
+	configMutex.Unlock()
+
+	return
+}
+
+// Sorted profile names from the config file
+func (f *File) Names() (names []string) {
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// The name of the active profile: the --profile flag, then the environment, then the config file's current profile.
+func ActiveProfileName() (name string, err error) {
+	var file *File
+
+	file, err = cachedFile()
+
+	if err == nil {
+		name = activeProfileName(file)
+	}
+
+	return
+}
+
+// Every configurable connection setting with its resolved value and source. Secrets are redacted.
+func Settings() (settings []Setting, err error) {
+	for _, key := range []string{VAR_BASE_URL, VAR_TENANT_ID, VAR_CONF_CLIENT_ID, VAR_CLIENT_SECRET, VAR_SAMPLE_DATA_PATH, VAR_GRANT_TYPE, VAR_USERNAME, VAR_PASSWORD, VAR_REFRESH_TOKEN} {
+		var setting Setting
+
+		setting, err = lookup(key)
+
+		if err != nil {
+			return
+		}
+
+		if secretKeys[key] && setting.Source != SOURCE_UNSET {
+			setting.Value = RedactSecret(setting.Value)
+		}
+
+		settings = append(settings, setting)
+	}
+
+	return
+}
+
+// Hides a secret value. References to where a secret is kept, such as env:NAME, are shown as they are.
+func RedactSecret(val string) string {
+	if len(val) == 0 || isSecretReference(val) {
+		return val
+	}
+
+	return REDACTED
+}
+
+// A copy of the profile with its secret redacted
+func (p Profile) Redacted() Profile {
+	p.ClientSecret = RedactSecret(p.ClientSecret)
+	p.Password = RedactSecret(p.Password)
+	p.RefreshToken = RedactSecret(p.RefreshToken)
+	return p
+}
+
+// Resolves a setting using the precedence flag > env > profile > default.
+func lookup(key string) (setting Setting, err error) {
+	var file *File
+
+	setting = Setting{Key: key, Source: SOURCE_UNSET}
+
+	configMutex.Lock()
+	override, overridden := overrides[key]
+	configMutex.Unlock()
+
+	if overridden {
+		setting.Value, setting.Source = override, SOURCE_FLAG
+		return
+	}
+
+	if val := os.Getenv(key); len(val) > 0 && !isPlaceholder(val) {
+		setting.Value, setting.Source = val, SOURCE_ENV
+		return
+	}
+
+	file, err = cachedFile()
+
+	if err == nil {
+		name := activeProfileName(file)
+
+		if profile, ok := file.Profiles[name]; ok {
+			if val := profile.value(key); len(val) > 0 {
+				setting.Value, setting.Source = val, SOURCE_PROFILE
+				return
+			}
+		} else if len(name) > 0 {
+			err = newError("Profile %s is not defined in the config file.", name)
+			logutil.LogError(err)
+			return
+		}
+	}
+
+	if val, ok := defaults[key]; ok && err == nil {
+		setting.Value, setting.Source = val, SOURCE_DEFAULT
+	}
+
+	return
+}
+
+func activeProfileName(file *File) string {
+	configMutex.Lock()
+	name := profileName
+	configMutex.Unlock()
+
+	if len(name) == 0 {
+		name = os.Getenv(VAR_PROFILE)
+	}
+
+	if len(name) == 0 {
+		name = file.CurrentProfile
+	}
+
+	return name
+}
+
+// Loads the config file once and reuses it for later lookups.
+func cachedFile() (file *File, err error) {
+	configMutex.Lock()
+	file = configFile
+	configMutex.Unlock()
+
+	if file == nil {
+		file, err = LoadFile()
+
+		if err == nil {
+			configMutex.Lock()
+			configFile = file
+			configMutex.Unlock()
+		}
+	}
+
+	return
+}
+
+func (p Profile) value(key string) string {
+	switch key {
+	case VAR_BASE_URL:
+		return p.BaseUrl
+	case VAR_TENANT_ID:
+		return p.TenantId
+	case VAR_CONF_CLIENT_ID:
+		return p.ConfClientId
+	case VAR_CLIENT_SECRET:
+		return p.ClientSecret
+	case VAR_SAMPLE_DATA_PATH:
+		return p.SampleDataPath
+	case VAR_GRANT_TYPE:
+		return p.GrantType
+	case VAR_USERNAME:
+		return p.Username
+	case VAR_PASSWORD:
+		return p.Password
+	case VAR_REFRESH_TOKEN:
+		return p.RefreshToken
+	}
+
+	return ""
+}
+
+// The sample .env file ships with <replace_with_...> placeholders. They are treated as unset so a profile can supply the value.
+func isPlaceholder(val string) bool {
+	return strings.HasPrefix(val, "<replace")
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	SECRET_FILE_PREFIX  = "file:"
+	SECRET_CMD_PREFIX   = "cmd:"
+	SECRET_STORE_PREFIX = "store:"
+)
+
+// Reads a secret from wherever a reference points to. The reference is passed without its prefix.
+type SecretResolver func(ref string) (secret string, err error)
+
+// Resolvers keyed by the prefix of the references they handle
+var secretResolvers = map[string]SecretResolver{
+	SECRET_ENV_PREFIX:   envSecret,
+	SECRET_FILE_PREFIX:  fileSecret,
+	SECRET_CMD_PREFIX:   cmdSecret,
+	SECRET_STORE_PREFIX: storeSecret,
+}
+
+// Secrets already resolved during this run, so helper commands and the secrets file are only read once
+var resolvedSecrets = make(map[string]string)
+var secretMutex sync.Mutex
+
+// Adds a resolver for secret references starting with prefix, e.g. "vault:", replacing any existing one.
+func RegisterSecretResolver(prefix string, resolver SecretResolver) {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+
+	secretResolvers[prefix] = resolver
+}
+
+// Whether a secret value refers to where the secret is kept rather than holding it
+func isSecretReference(val string) bool {
+	_, _, ok := secretResolver(val)
+	return ok
+}
+
+// Reads a secret from the place a reference points to. Other values are returned as they are.
+func resolveSecret(val string) (secret string, err error) {
+	secret = val
+
+	prefix, resolver, ok := secretResolver(val)
+
+	if !ok {
+		return
+	}
+
+	secretMutex.Lock()
+	cached, found := resolvedSecrets[val]
+	secretMutex.Unlock()
+
+	if found {
+		return cached, nil
+	}
+
+	secret, err = resolver(strings.TrimPrefix(val, prefix))
+
+	if err == nil && len(secret) == 0 {
+		err = errors.New("the secret is empty")
+	}
+
+	if err == nil {
+		secretMutex.Lock()
+		resolvedSecrets[val] = secret
+		secretMutex.Unlock()
+	} else {
+		err = newError("Unable to resolve secret reference %s: %s", val, err)
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+// Finds the resolver for a reference, preferring the longest matching prefix.
+func secretResolver(val string) (prefix string, resolver SecretResolver, ok bool) {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+
+	var prefixes []string
+	for p := range secretResolvers {
+		prefixes = append(prefixes, p)
+	}
+
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(val, p) {
+			return p, secretResolvers[p], true
+		}
+	}
+
+	return
+}
+
+// env:NAME reads the secret from an environment variable.
+func envSecret(name string) (secret string, err error) {
+	secret = os.Getenv(name)
+
+	if len(secret) == 0 {
+		err = errors.New("the environment variable is missing")
+	}
+
+	return
+}
+
+// file:PATH reads the secret from a file, such as a Docker or Kubernetes mounted secret.
+func fileSecret(path string) (secret string, err error) {
+	var contents []byte
+
+	contents, err = os.ReadFile(path)
+
+	if err == nil {
+		secret = trimLineEnding(string(contents))
+	}
+
+	return
+}
+
+// cmd:COMMAND runs a helper through the shell and reads the secret from its standard output.
+// The helper's standard error is passed through so it can prompt, e.g. to unlock a password manager.
+func cmdSecret(command string) (secret string, err error) {
+	var stdout bytes.Buffer
+	var helper *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		helper = exec.Command("cmd", "/C", command)
+	} else {
+		helper = exec.Command("sh", "-c", command)
+	}
+
+	helper.Stdin = os.Stdin
+	helper.Stdout = &stdout
+	helper.Stderr = os.Stderr
+
+	err = helper.Run()
+
+	if err == nil {
+		secret = trimLineEnding(stdout.String())
+	}
+
+	return
+}
+
+// store:NAME reads the secret from the encrypted secrets file.
+func storeSecret(name string) (secret string, err error) {
+	var secrets map[string]string
+
+	secrets, err = cachedSecrets()
+
+	if err == nil {
+		var ok bool
+		if secret, ok = secrets[name]; !ok {
+			err = errors.New("the secret is not in the secrets file")
+		}
+	}
+
+	return
+}
+
+// Files and helpers usually end their output with a newline that isn't part of the secret.
+func trimLineEnding(val string) string {
+	return strings.TrimRight(val, "\r\n")
+}
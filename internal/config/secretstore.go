@@ -0,0 +1,289 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	VAR_SECRETS_FILE       = "CMS_DEMO_SECRETS_FILE"
+	VAR_SECRETS_PASSPHRASE = "CMS_DEMO_SECRETS_PASSPHRASE"
+
+	secretsFileVersion = 1
+)
+
+// scrypt parameters used to derive the AES-256 key from the passphrase
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// The encrypted secrets file. Data holds the secrets as a JSON object sealed with AES-GCM.
+type secretsFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+var storedSecrets map[string]string
+var secretsPassphrase string
+
+// The path to the encrypted secrets file
+func SecretsFilePath() (path string, err error) {
+	path = os.Getenv(VAR_SECRETS_FILE)
+	if len(path) == 0 {
+		path, err = os.UserConfigDir()
+		if err == nil {
+			path = filepath.Join(path, "planets", "secrets.enc")
+		} else {
+			err = newError("Unable to find a directory for the secrets file, set %s instead.", VAR_SECRETS_FILE)
+			logutil.LogError(err)
+		}
+	}
+	return
+}
+
+// Whether the encrypted secrets file has been created
+func SecretsFileExists() bool {
+	path, err := SecretsFilePath()
+	if err == nil {
+		_, err = os.Stat(path)
+	}
+	return err == nil
+}
+
+// The passphrase unlocking the secrets file, from the environment or prompted for.
+// When confirm is set the passphrase is prompted for twice, as when a new secrets file is created.
+func SecretsPassphrase(confirm bool) (passphrase string, err error) {
+	passphrase = os.Getenv(VAR_SECRETS_PASSPHRASE)
+
+	if len(passphrase) > 0 {
+		return
+	}
+
+	if len(secretsPassphrase) > 0 {
+		return secretsPassphrase, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		err = newError("%s is missing and can't be prompted for without a terminal.", VAR_SECRETS_PASSPHRASE)
+		logutil.LogError(err)
+		return
+	}
+
+	passphrase, err = promptSecret("Secrets file passphrase: ")
+
+	if err == nil && confirm {
+		var again string
+
+		again, err = promptSecret("Confirm passphrase: ")
+
+		if err == nil && again != passphrase {
+			err = newError("The passphrases don't match.")
+			logutil.LogError(err)
+		}
+	}
+
+	if err == nil && len(passphrase) == 0 {
+		err = newError("The secrets file passphrase can't be empty.")
+		logutil.LogError(err)
+	}
+
+	if err == nil {
+		secretsPassphrase = passphrase
+	}
+
+	return
+}
+
+// Reads and decrypts the secrets file. A missing file is treated as one with no secrets.
+func LoadSecrets(passphrase string) (secrets map[string]string, err error) {
+	var path string
+	var contents []byte
+	var file secretsFile
+	var gcm cipher.AEAD
+	var plaintext []byte
+
+	secrets = make(map[string]string)
+
+	path, err = SecretsFilePath()
+
+	if err == nil {
+		contents, err = os.ReadFile(path)
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return secrets, nil
+		}
+	}
+
+	if err == nil {
+		err = json.Unmarshal(contents, &file)
+
+		if err == nil && file.Version != secretsFileVersion {
+			err = fmt.Errorf("unsupported version %d", file.Version)
+		}
+
+		if err != nil {
+			err = newError("Secrets file %s is not valid: %s", path, err)
+		}
+	}
+
+	if err == nil {
+		gcm, err = secretsCipher(passphrase, file.Salt)
+	}
+
+	if err == nil {
+		plaintext, err = gcm.Open(nil, file.Nonce, file.Data, nil)
+
+		if err != nil {
+			err = newError("Unable to decrypt secrets file %s, the passphrase may be wrong.", path)
+		}
+	}
+
+	if err == nil {
+		err = json.Unmarshal(plaintext, &secrets)
+	}
+
+	if err != nil {
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+// Encrypts the secrets with a key derived from the passphrase and writes the secrets file,
+// readable only by the current user. A new salt and nonce are used on every write.
+func SaveSecrets(secrets map[string]string, passphrase string) (err error) {
+	var path string
+	var plaintext []byte
+	var contents []byte
+	var gcm cipher.AEAD
+
+	file := secretsFile{Version: secretsFileVersion, Salt: make([]byte, 16)}
+
+	path, err = SecretsFilePath()
+
+	if err == nil {
+		_, err = rand.Read(file.Salt)
+	}
+
+	if err == nil {
+		gcm, err = secretsCipher(passphrase, file.Salt)
+	}
+
+	if err == nil {
+		file.Nonce = make([]byte, gcm.NonceSize())
+		_, err = rand.Read(file.Nonce)
+	}
+
+	if err == nil {
+		plaintext, err = json.Marshal(secrets)
+	}
+
+	if err == nil {
+		file.Data = gcm.Seal(nil, file.Nonce, plaintext, nil)
+		contents, err = json.MarshalIndent(file, "", "  ")
+	}
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+
+	if err == nil {
+		err = os.WriteFile(path, contents, 0600)
+	}
+
+	if err != nil {
+		logutil.LogError(err)
+	}
+
+	secretMutex.Lock()
+	resolvedSecrets = make(map[string]string)
+	secretMutex.Unlock()
+
+	configMutex.Lock()
+	storedSecrets = nil
+	configMutex.Unlock()
+
+	return
+}
+
+// Decrypts the secrets file once and reuses it for later store: references.
+func cachedSecrets() (cached map[string]string, err error) {
+	var passphrase string
+
+	configMutex.Lock()
+	cached = storedSecrets
+	configMutex.Unlock()
+
+	if cached != nil {
+		return
+	}
+
+	if !SecretsFileExists() {
+		path, _ := SecretsFilePath()
+		err = fmt.Errorf("the secrets file %s does not exist", path)
+		return
+	}
+
+	passphrase, err = SecretsPassphrase(false)
+
+	if err == nil {
+		cached, err = LoadSecrets(passphrase)
+	}
+
+	if err == nil {
+		configMutex.Lock()
+		storedSecrets = cached
+		configMutex.Unlock()
+	}
+
+	return
+}
+
+// An AES-256-GCM cipher keyed from the passphrase with scrypt, so guessing passphrases is slow.
+func secretsCipher(passphrase string, salt []byte) (gcm cipher.AEAD, err error) {
+	var key []byte
+	var block cipher.Block
+
+	key, err = scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+
+	if err == nil {
+		block, err = aes.NewCipher(key)
+	}
+
+	if err == nil {
+		gcm, err = cipher.NewGCM(block)
+	}
+
+	return
+}
+
+// Reads a value without echoing it to the terminal.
+func promptSecret(prompt string) (val string, err error) {
+	var valBytes []byte
+
+	fmt.Fprint(os.Stderr, prompt)
+	valBytes, err = term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+
+	if err == nil {
+		val = string(valBytes)
+	}
+
+	return
+}
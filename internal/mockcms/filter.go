@@ -0,0 +1,124 @@
+package mockcms
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// A single comparison from a filter query parameter, e.g. properties.diameter gt 10000
+type filter struct {
+	field    string
+	operator string
+	value    string
+}
+
+var filterPattern = regexp.MustCompile(`^\s*([\w.]+)\s+(eq|ne|gt|ge|lt|le|contains)\s+('(?:[^']|'')*'|\S+)\s*$`)
+var andPattern = regexp.MustCompile(`\s+and\s+`)
+
+// Parses comparisons joined with and, as sent by the cli.
+func parseFilters(query string) (filters []filter, err error) {
+	if len(strings.TrimSpace(query)) == 0 {
+		return
+	}
+
+	for _, expression := range splitAnd(query) {
+		match := filterPattern.FindStringSubmatch(expression)
+
+		if match == nil {
+			err = fmt.Errorf("Invalid filter %q", expression)
+			return
+		}
+
+		value := match[3]
+		if strings.HasPrefix(value, "'") {
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+
+		filters = append(filters, filter{field: match[1], operator: match[2], value: value})
+	}
+
+	return
+}
+
+// Splits on and, ignoring any inside quoted values.
+func splitAnd(query string) (expressions []string) {
+	start := 0
+	quoted := false
+
+	for i := 0; i < len(query); i++ {
+		if query[i] == '\'' {
+			quoted = !quoted
+		} else if !quoted {
+			if loc := andPattern.FindStringIndex(query[i:]); loc != nil && loc[0] == 0 {
+				expressions = append(expressions, query[start:i])
+				i += loc[1] - 1
+				start = i + 1
+			}
+		}
+	}
+
+	return append(expressions, query[start:])
+}
+
+func matchesAll(record gjson.Result, filters []filter) bool {
+	for _, f := range filters {
+		if !f.matches(record.Get(f.field)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f filter) matches(value gjson.Result) bool {
+	if f.operator == "contains" {
+		return strings.Contains(strings.ToLower(value.String()), strings.ToLower(f.value))
+	}
+
+	var c int
+
+	if number, err := strconv.ParseFloat(f.value, 64); err == nil && value.Type == gjson.Number {
+		c = compareFloat(value.Float(), number)
+	} else {
+		c = strings.Compare(value.String(), f.value)
+	}
+
+	switch f.operator {
+	case "eq":
+		return c == 0
+	case "ne":
+		return c != 0
+	case "gt":
+		return c > 0
+	case "ge":
+		return c >= 0
+	case "lt":
+		return c < 0
+	}
+
+	return c <= 0
+}
+
+// Compares numbers numerically and anything else as strings.
+func compare(a gjson.Result, b gjson.Result) int {
+	if a.Type == gjson.Number && b.Type == gjson.Number {
+		return compareFloat(a.Float(), b.Float())
+	}
+
+	return strings.Compare(a.String(), b.String())
+}
+
+func compareFloat(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
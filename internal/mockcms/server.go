@@ -0,0 +1,580 @@
+// The mockcms package is an in-memory fake of the OCP token endpoint and the CMS instance endpoints,
+// for developing without a tenant and for running the integration tests.
+package mockcms
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DefaultPageSize      = 10
+	DefaultTokenLifetime = time.Hour
+
+	deleteLinkRel = "urn:eim:linkrel:delete"
+)
+
+// Configures the fake. Empty credentials accept any client, user or password.
+// A FaultRate between 0 and 1 fails that share of CMS requests with FaultStatus, e.g. 429 or 503.
+type Options struct {
+	ClientId      string
+	ClientSecret  string
+	Username      string
+	Password      string
+	PageSize      int
+	TokenLifetime time.Duration
+	FaultRate     float64
+	FaultStatus   int
+}
+
+// A CMS instance as stored by the fake
+type Instance struct {
+	Id         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Category   string                 `json:"category"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	CreateTime string                 `json:"create_time"`
+	UpdateTime string                 `json:"update_time"`
+}
+
+// The fake server. It implements http.Handler so it can be run with http.ListenAndServe or httptest.NewServer.
+type Server struct {
+	opts          Options
+	mu            sync.Mutex
+	collections   map[string][]*Instance
+	accessTokens  map[string]time.Time
+	refreshTokens map[string]bool
+	faults        []int
+	nextId        int
+	requests      int
+}
+
+// Creates an empty fake.
+func NewServer(opts Options) *Server {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	if opts.TokenLifetime <= 0 {
+		opts.TokenLifetime = DefaultTokenLifetime
+	}
+
+	if opts.FaultStatus == 0 {
+		opts.FaultStatus = http.StatusServiceUnavailable
+	}
+
+	return &Server{
+		opts:          opts,
+		collections:   make(map[string][]*Instance),
+		accessTokens:  make(map[string]time.Time),
+		refreshTokens: make(map[string]bool),
+	}
+}
+
+// Adds an instance directly, e.g. to set up a test.
+func (s *Server) Seed(category string, systemTypeName string, name string, properties map[string]interface{}) *Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.create(category, systemTypeName, name, properties)
+}
+
+// Sets a property of an instance directly, e.g. to change CMS behind the cli's back. Unknown ids are ignored.
+func (s *Server) SetProperty(category string, systemTypeName string, id string, property string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, instance := range s.collections[collectionKey(category, systemTypeName)] {
+		if instance.Id == id {
+			if instance.Properties == nil {
+				instance.Properties = make(map[string]interface{})
+			}
+
+			instance.Properties[property] = value
+			instance.UpdateTime = now()
+		}
+	}
+}
+
+// A copy of the instances of a type, in the order they were created.
+func (s *Server) Instances(category string, systemTypeName string) (instances []Instance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, instance := range s.collections[collectionKey(category, systemTypeName)] {
+		instances = append(instances, *instance)
+	}
+
+	return
+}
+
+// Fails the next count CMS requests with the given status code.
+// A status of 0 lets a request through, so a fault can be aimed at a later request.
+func (s *Server) InjectFaults(status int, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < count; i++ {
+		s.faults = append(s.faults, status)
+	}
+}
+
+// Invalidates every access token issued so far, as if they had expired.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accessTokens = make(map[string]time.Time)
+}
+
+// The number of requests received, including token requests.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	w.Header().Set("X-Request-Id", fmt.Sprintf("mock-request-%d", s.requests))
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 4 && parts[0] == "tenants" && parts[2] == "oauth2" && parts[3] == "token":
+		s.token(w, r)
+	case len(parts) >= 4 && len(parts) <= 5 && parts[0] == "cms" && parts[1] == "instances":
+		if s.fault(w) || !s.authorized(w, r) {
+			return
+		}
+
+		category, systemTypeName := parts[2], parts[3]
+
+		if len(parts) == 4 {
+			s.collection(w, r, category, systemTypeName)
+		} else {
+			s.instance(w, r, category, systemTypeName, parts[4])
+		}
+	default:
+		writeError(w, http.StatusNotFound, "No such endpoint")
+	}
+}
+
+// POST /tenants/{tenant}/oauth2/token
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	var grant struct {
+		ClientId     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		GrantType    string `json:"grant_type"`
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Use POST to fetch a token")
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&grant); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid token request body")
+		return
+	}
+
+	if !matches(s.opts.ClientId, grant.ClientId) || !matches(s.opts.ClientSecret, grant.ClientSecret) {
+		writeError(w, http.StatusUnauthorized, "Invalid client credentials")
+		return
+	}
+
+	issueRefreshToken := false
+
+	switch grant.GrantType {
+	case "client_credentials":
+	case "password":
+		if len(grant.Username) == 0 || !matches(s.opts.Username, grant.Username) || !matches(s.opts.Password, grant.Password) {
+			writeError(w, http.StatusUnauthorized, "Invalid username or password")
+			return
+		}
+		issueRefreshToken = true
+	case "refresh_token":
+		if !s.refreshTokens[grant.RefreshToken] {
+			writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		delete(s.refreshTokens, grant.RefreshToken)
+		issueRefreshToken = true
+	default:
+		writeError(w, http.StatusBadRequest, "Unsupported grant type "+grant.GrantType)
+		return
+	}
+
+	s.nextId++
+	accessToken := fmt.Sprintf("mock-access-token-%d", s.nextId)
+	s.accessTokens[accessToken] = time.Now().Add(s.opts.TokenLifetime)
+
+	body := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "bearer",
+		"expires_in":   int(s.opts.TokenLifetime.Seconds()),
+	}
+
+	if issueRefreshToken {
+		refreshToken := fmt.Sprintf("mock-refresh-token-%d", s.nextId)
+		s.refreshTokens[refreshToken] = true
+		body["refresh_token"] = refreshToken
+	}
+
+	writeJSON(w, http.StatusOK, body)
+}
+
+// GET and POST /cms/instances/{category}/{type}
+func (s *Server) collection(w http.ResponseWriter, r *http.Request, category string, systemTypeName string) {
+	switch r.Method {
+	case http.MethodGet:
+		s.list(w, r, category, systemTypeName)
+	case http.MethodPost:
+		name, properties, ok := readInstanceBody(w, r, true)
+
+		if ok {
+			instance := s.create(category, systemTypeName, name, properties)
+			writeJSON(w, http.StatusCreated, s.render(r, instance, nil))
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Use GET or POST on a collection")
+	}
+}
+
+// GET, PUT and DELETE /cms/instances/{category}/{type}/{id}
+func (s *Server) instance(w http.ResponseWriter, r *http.Request, category string, systemTypeName string, id string) {
+	key := collectionKey(category, systemTypeName)
+	index := -1
+
+	for i, instance := range s.collections[key] {
+		if instance.Id == id {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Instance %s of type %s not found", id, systemTypeName))
+		return
+	}
+
+	instance := s.collections[key][index]
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.render(r, instance, nil))
+	case http.MethodPut, http.MethodPatch:
+		name, properties, ok := readInstanceBody(w, r, r.Method == http.MethodPut)
+
+		if ok {
+			if len(name) > 0 {
+				instance.Name = name
+			}
+
+			if r.Method == http.MethodPut {
+				instance.Properties = properties
+			} else {
+				for property, value := range properties {
+					instance.Properties[property] = value
+				}
+			}
+
+			instance.UpdateTime = now()
+			writeJSON(w, http.StatusOK, s.render(r, instance, nil))
+		}
+	case http.MethodDelete:
+		s.collections[key] = append(s.collections[key][:index], s.collections[key][index+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Use GET, PUT, PATCH or DELETE on an instance")
+	}
+}
+
+// Lists a page of a collection in the HAL shape used by CMS, applying the filter, sortby and fields query parameters.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, category string, systemTypeName string) {
+	var filters []filter
+	var err error
+
+	query := r.URL.Query()
+	page := positiveInt(query.Get("page"), 1)
+	pageSize := positiveInt(query.Get("items-per-page"), s.opts.PageSize)
+
+	if filters, err = parseFilters(query.Get("filter")); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var matching []gjson.Result
+
+	for _, instance := range s.collections[collectionKey(category, systemTypeName)] {
+		rendered := gjson.Parse(string(mustJSON(instance)))
+
+		if matchesAll(rendered, filters) {
+			matching = append(matching, rendered)
+		}
+	}
+
+	if sortBy := query.Get("sortby"); len(sortBy) > 0 {
+		sortResults(matching, sortBy)
+	}
+
+	totalPages := (len(matching) + pageSize - 1) / pageSize
+	start := (page - 1) * pageSize
+	end := start + pageSize
+
+	if start > len(matching) {
+		start = len(matching)
+	}
+
+	if end > len(matching) {
+		end = len(matching)
+	}
+
+	var fields []string
+	if projection := query.Get("fields"); len(projection) > 0 {
+		fields = strings.Split(projection, ",")
+	}
+
+	collection := []interface{}{}
+
+	for _, result := range matching[start:end] {
+		var instance Instance
+		json.Unmarshal([]byte(result.Raw), &instance)
+		collection = append(collection, s.render(r, &instance, fields))
+	}
+
+	links := map[string]interface{}{"self": link(pageUrl(r, page, pageSize))}
+
+	if page < totalPages {
+		links["next"] = link(pageUrl(r, page+1, pageSize))
+	}
+
+	if page > 1 {
+		links["prev"] = link(pageUrl(r, page-1, pageSize))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_embedded": map[string]interface{}{"collection": collection},
+		"_links":    links,
+		"page": map[string]interface{}{
+			"number":        page,
+			"size":          pageSize,
+			"totalElements": len(matching),
+			"totalPages":    totalPages,
+		},
+	})
+}
+
+func (s *Server) create(category string, systemTypeName string, name string, properties map[string]interface{}) *Instance {
+	s.nextId++
+
+	instance := &Instance{
+		Id:         fmt.Sprintf("%08d-0000-4000-8000-%012d", s.nextId, s.nextId),
+		Name:       name,
+		Type:       systemTypeName,
+		Category:   category,
+		Properties: properties,
+		CreateTime: now(),
+	}
+
+	instance.UpdateTime = instance.CreateTime
+
+	if instance.Properties == nil {
+		instance.Properties = make(map[string]interface{})
+	}
+
+	key := collectionKey(category, systemTypeName)
+	s.collections[key] = append(s.collections[key], instance)
+
+	return instance
+}
+
+// The instance as CMS returns it, with its HAL links. Only the given properties are included when fields are set.
+func (s *Server) render(r *http.Request, instance *Instance, fields []string) map[string]interface{} {
+	href := fmt.Sprintf("%s/cms/instances/%s/%s/%s", baseUrl(r), instance.Category, instance.Type, instance.Id)
+
+	properties := instance.Properties
+
+	if len(fields) > 0 {
+		properties = make(map[string]interface{})
+
+		for _, field := range fields {
+			name := strings.TrimPrefix(field, "properties.")
+
+			if value, ok := instance.Properties[name]; ok {
+				properties[name] = value
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"id":          instance.Id,
+		"name":        instance.Name,
+		"type":        instance.Type,
+		"category":    instance.Category,
+		"properties":  properties,
+		"create_time": instance.CreateTime,
+		"update_time": instance.UpdateTime,
+		"_links": map[string]interface{}{
+			"self":        link(href),
+			deleteLinkRel: link(href),
+		},
+	}
+}
+
+// Fails the request with a queued or random fault. Rate limit and unavailable responses ask the client to retry at once.
+func (s *Server) fault(w http.ResponseWriter) bool {
+	status := 0
+
+	if len(s.faults) > 0 {
+		status, s.faults = s.faults[0], s.faults[1:]
+	} else if s.opts.FaultRate > 0 && rand.Float64() < s.opts.FaultRate {
+		status = s.opts.FaultStatus
+	}
+
+	if status == 0 {
+		return false
+	}
+
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "0")
+	}
+
+	writeError(w, status, "Injected fault")
+
+	return true
+}
+
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if expiresAt, ok := s.accessTokens[accessToken]; ok && time.Now().Before(expiresAt) {
+		return true
+	}
+
+	writeError(w, http.StatusUnauthorized, "Missing, invalid or expired access token")
+
+	return false
+}
+
+// Reads a create, update or patch body. Patches may leave the name out to keep the current one.
+func readInstanceBody(w http.ResponseWriter, r *http.Request, nameRequired bool) (name string, properties map[string]interface{}, ok bool) {
+	var body struct {
+		Name       string                 `json:"name"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid instance body")
+		return
+	}
+
+	if nameRequired && len(body.Name) == 0 {
+		writeError(w, http.StatusBadRequest, "An instance name is required")
+		return
+	}
+
+	if body.Properties == nil {
+		body.Properties = make(map[string]interface{})
+	}
+
+	return body.Name, body.Properties, true
+}
+
+func sortResults(results []gjson.Result, sortBy string) {
+	var sorts [][2]string
+
+	for _, part := range strings.Split(sortBy, ",") {
+		fields := strings.Fields(part)
+
+		if len(fields) > 0 {
+			direction := "asc"
+			if len(fields) > 1 {
+				direction = strings.ToLower(fields[1])
+			}
+			sorts = append(sorts, [2]string{fields[0], direction})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		for _, s := range sorts {
+			c := compare(results[i].Get(s[0]), results[j].Get(s[0]))
+
+			if c != 0 {
+				return (c < 0) == (s[1] != "desc")
+			}
+		}
+
+		return false
+	})
+}
+
+func pageUrl(r *http.Request, page int, pageSize int) string {
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("items-per-page", strconv.Itoa(pageSize))
+
+	return fmt.Sprintf("%s%s?%s", baseUrl(r), r.URL.Path, query.Encode())
+}
+
+func baseUrl(r *http.Request) string {
+	return "http://" + r.Host
+}
+
+func link(href string) map[string]string {
+	return map[string]string{"href": href}
+}
+
+func collectionKey(category string, systemTypeName string) string {
+	return category + "/" + systemTypeName
+}
+
+func positiveInt(val string, defaultVal int) int {
+	if n, err := strconv.Atoi(val); err == nil && n > 0 {
+		return n
+	}
+
+	return defaultVal
+}
+
+// An empty expected value accepts anything.
+func matches(expected string, actual string) bool {
+	return len(expected) == 0 || expected == actual
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func mustJSON(v interface{}) []byte {
+	body, _ := json.Marshal(v)
+	return body
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/hal+json")
+	w.WriteHeader(status)
+	w.Write(mustJSON(body))
+}
+
+// Errors carry the status, a code such as NOT_FOUND and a message.
+func writeError(w http.ResponseWriter, status int, message string) {
+	code := strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	writeJSON(w, status, map[string]interface{}{"status": status, "code": code, "message": message})
+}
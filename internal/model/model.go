@@ -0,0 +1,249 @@
+// The model package loads CMS namespace and type definitions from the OpenText Cloud Developer Tools model files.
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"ocp/sample/planets/internal/config"
+	ioutil "ocp/sample/planets/internal/util/io"
+	logutil "ocp/sample/planets/internal/util/log"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	typeFileExt      = ".ottype"
+	namespaceFileExt = ".otns"
+)
+
+// The subset of the .otproject file needed to find the model files
+type project struct {
+	ModelFolders []string `json:"modelFolders"`
+}
+
+// The envelope shared by every model file
+type modelFile struct {
+	Id       string          `json:"id"`
+	SchemaId string          `json:"schemaId"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// A CMS namespace defined in a .otns file
+type Namespace struct {
+	Id          string `json:"-"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Prefix      string `json:"prefix"`
+	Description string `json:"description"`
+}
+
+// A CMS type attribute defined in a .ottype file
+type Attribute struct {
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	DataType    string            `json:"data_type"`
+	Required    bool              `json:"required"`
+	Validators  []json.RawMessage `json:"validators"`
+}
+
+// A CMS type defined in a .ottype file
+type Type struct {
+	Id          string      `json:"-"`
+	Path        string      `json:"-"`
+	Category    string      `json:"category"`
+	Name        string      `json:"name"`
+	DisplayName string      `json:"display_name"`
+	NamespaceId string      `json:"namespace"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	Namespace   *Namespace  `json:"-"`
+}
+
+// All namespaces and types found in a project's model folders, keyed by id and system type name respectively
+type Model struct {
+	Namespaces map[string]*Namespace
+	Types      map[string]*Type
+}
+
+var cachedModel *Model
+
+// The system type name used by CMS, made up of the namespace prefix and the type name, e.g. un_planet
+func (t *Type) SystemName() string {
+	if t.Namespace == nil || len(t.Namespace.Prefix) == 0 {
+		return t.Name
+	}
+
+	return fmt.Sprintf("%s_%s", t.Namespace.Prefix, t.Name)
+}
+
+// Gets an attribute by name
+func (t *Type) Attribute(name string) (attribute Attribute, ok bool) {
+	for _, attribute = range t.Attributes {
+		if attribute.Name == name {
+			return attribute, true
+		}
+	}
+
+	return Attribute{}, false
+}
+
+// Gets a type by its system type name, e.g. un_planet
+func (m *Model) Type(systemTypeName string) (t *Type, err error) {
+	t, ok := m.Types[systemTypeName]
+
+	if !ok {
+		err = fmt.Errorf("No model found for type %s", systemTypeName)
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+// Loads the model from the project file in the environment, caching it for later calls.
+func Cached() (m *Model, err error) {
+	if cachedModel != nil {
+		return cachedModel, nil
+	}
+
+	var projectPath string
+
+	projectPath, err = config.ProjectPath()
+
+	if err == nil {
+		m, err = Load(projectPath)
+	}
+
+	if err == nil {
+		cachedModel = m
+	}
+
+	return
+}
+
+// Loads every namespace and type from the model folders listed in a .otproject file.
+// Model folders are resolved relative to the project file.
+func Load(projectPath string) (m *Model, err error) {
+	var projectJSON string
+	var proj project
+
+	projectJSON, err = ioutil.ReadFileAsString(projectPath)
+
+	if err == nil {
+		err = json.Unmarshal([]byte(projectJSON), &proj)
+	}
+
+	if err != nil {
+		logutil.LogError(err)
+		return
+	}
+
+	m = &Model{
+		Namespaces: make(map[string]*Namespace),
+		Types:      make(map[string]*Type),
+	}
+
+	var types []*Type
+	projectDir := filepath.Dir(projectPath)
+
+	for _, folder := range proj.ModelFolders {
+		var folderTypes []*Type
+
+		folderTypes, err = m.loadFolder(filepath.Join(projectDir, folder))
+
+		if err != nil {
+			return nil, err
+		}
+
+		types = append(types, folderTypes...)
+	}
+
+	for _, t := range types {
+		t.Namespace = m.Namespaces[t.NamespaceId]
+		m.Types[t.SystemName()] = t
+	}
+
+	return
+}
+
+// Walks a model folder collecting namespaces into the model and returning the types found.
+func (m *Model) loadFolder(folder string) (types []*Type, err error) {
+	err = filepath.WalkDir(folder, func(path string, entry fs.DirEntry, walkErr error) (err error) {
+		if walkErr != nil || entry.IsDir() {
+			return walkErr
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case namespaceFileExt:
+			var ns *Namespace
+
+			ns, err = loadNamespace(path)
+
+			if err == nil {
+				m.Namespaces[ns.Id] = ns
+			}
+		case typeFileExt:
+			var t *Type
+
+			t, err = loadType(path)
+
+			if err == nil {
+				types = append(types, t)
+			}
+		}
+
+		return
+	})
+
+	if err != nil {
+		logutil.LogError(err)
+	}
+
+	return
+}
+
+func loadNamespace(path string) (ns *Namespace, err error) {
+	var file modelFile
+
+	file, err = readModelFile(path)
+
+	if err == nil {
+		ns = &Namespace{Id: file.Id}
+		err = json.Unmarshal(file.Data, ns)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("Unable to read namespace %s: %w", path, err)
+	}
+
+	return
+}
+
+func loadType(path string) (t *Type, err error) {
+	var file modelFile
+
+	file, err = readModelFile(path)
+
+	if err == nil {
+		t = &Type{Id: file.Id, Path: path}
+		err = json.Unmarshal(file.Data, t)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("Unable to read type %s: %w", path, err)
+	}
+
+	return
+}
+
+func readModelFile(path string) (file modelFile, err error) {
+	var contents string
+
+	contents, err = ioutil.ReadFileAsString(path)
+
+	if err == nil {
+		err = json.Unmarshal([]byte(contents), &file)
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DataTypeInteger = "integer"
+	DataTypeDouble  = "double"
+	DataTypeString  = "string"
+	DataTypeBoolean = "boolean"
+	DataTypeDate    = "date"
+)
+
+// Builds the CMS properties for a record using the type's attributes.
+// Only attributes present in the record are included, converted to the attribute's data type.
+func (t *Type) Properties(record gjson.Result) map[string]interface{} {
+	return t.properties(record, false)
+}
+
+// Builds the CMS properties for a record using only the type's required attributes.
+func (t *Type) RequiredProperties(record gjson.Result) map[string]interface{} {
+	return t.properties(record, true)
+}
+
+func (t *Type) properties(record gjson.Result, requiredOnly bool) map[string]interface{} {
+	props := make(map[string]interface{})
+
+	for _, attribute := range t.Attributes {
+		if requiredOnly && !attribute.Required {
+			continue
+		}
+
+		value := record.Get(attribute.Name)
+
+		if value.Exists() && value.Type != gjson.Null {
+			props[attribute.Name] = attribute.Value(value)
+		}
+	}
+
+	return props
+}
+
+// Converts a JSON value to the Go value matching the attribute's data type.
+func (a Attribute) Value(value gjson.Result) interface{} {
+	switch a.DataType {
+	case DataTypeInteger:
+		return value.Int()
+	case DataTypeDouble:
+		return value.Float()
+	case DataTypeBoolean:
+		return value.Bool()
+	case DataTypeString, DataTypeDate:
+		return value.String()
+	default:
+		return value.Value()
+	}
+}
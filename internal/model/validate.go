@@ -0,0 +1,244 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	ValidatorMin       = "min"
+	ValidatorMax       = "max"
+	ValidatorMinLength = "min_length"
+	ValidatorMaxLength = "max_length"
+	ValidatorPattern   = "pattern"
+	ValidatorEnum      = "enum"
+)
+
+// Date formats accepted for attributes with the date data type
+var dateFormats = []string{time.RFC3339, "2006-01-02"}
+
+// A single problem found with a data record
+type Violation struct {
+	File      string
+	Record    int
+	Name      string
+	Attribute string
+	Message   string
+}
+
+// Every violation found while validating a set of records
+type ValidationError struct {
+	Violations []Violation
+}
+
+// A validator from the attribute's "validators" array, e.g. {"type": "max", "value": 100}
+type validator struct {
+	Type   string          `json:"type"`
+	Value  json.RawMessage `json:"value"`
+	Values []interface{}   `json:"values"`
+}
+
+func (v Violation) String() string {
+	location := v.File
+
+	if v.Record > 0 {
+		location = fmt.Sprintf("%s: record %d", location, v.Record)
+	}
+
+	if len(v.Name) > 0 {
+		location = fmt.Sprintf("%s (%s)", location, v.Name)
+	}
+
+	if len(v.Attribute) > 0 {
+		location = fmt.Sprintf("%s: %s", location, v.Attribute)
+	}
+
+	return fmt.Sprintf("%s: %s", location, v.Message)
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d validation error(s) found", len(e.Violations))
+}
+
+// Validates a JSON array of flat data records against the type's attributes.
+// The file is only used to report the location of each violation; records are numbered from 1.
+// Problems with the model's own validators are reported once, before the records.
+func (t *Type) ValidateRecords(file string, records gjson.Result) (violations []Violation) {
+	violations = t.CheckValidators()
+
+	if !records.IsArray() {
+		return append(violations, Violation{File: file, Message: "data must be a JSON array of records"})
+	}
+
+	index := 0
+
+	records.ForEach(func(_, record gjson.Result) bool {
+		index++
+		violations = append(violations, t.ValidateRecord(file, index, record)...)
+		return true
+	})
+
+	return
+}
+
+// Validates a single flat data record against the type's attributes.
+func (t *Type) ValidateRecord(file string, index int, record gjson.Result) (violations []Violation) {
+	name := record.Get("name").String()
+	violation := func(attribute string, message string) Violation {
+		return Violation{File: file, Record: index, Name: name, Attribute: attribute, Message: message}
+	}
+
+	if !record.IsObject() {
+		return []Violation{violation("", "record must be a JSON object")}
+	}
+
+	if len(name) == 0 {
+		violations = append(violations, violation("name", "is required"))
+	}
+
+	for _, attribute := range t.Attributes {
+		value := record.Get(attribute.Name)
+
+		if !value.Exists() || value.Type == gjson.Null {
+			if attribute.Required {
+				violations = append(violations, violation(attribute.Name, "is required"))
+			}
+			continue
+		}
+
+		for _, message := range attribute.Validate(value) {
+			violations = append(violations, violation(attribute.Name, message))
+		}
+	}
+
+	return
+}
+
+// Checks that every validator in the model can be read and every pattern compiles. Records aren't
+// checked against broken validators, so each problem is reported once here rather than for every record.
+func (t *Type) CheckValidators() (violations []Violation) {
+	for _, attribute := range t.Attributes {
+		for _, raw := range attribute.Validators {
+			var v validator
+			var message string
+
+			if err := json.Unmarshal(raw, &v); err != nil {
+				message = fmt.Sprintf("has an unreadable validator %s", string(raw))
+			} else if _, err = v.pattern(); err != nil {
+				message = fmt.Sprintf("has an invalid pattern validator %s: %s", string(v.Value), err)
+			}
+
+			if len(message) > 0 {
+				violations = append(violations, Violation{File: t.Path, Attribute: attribute.Name, Message: message})
+			}
+		}
+	}
+
+	return
+}
+
+// Checks a value against the attribute's data type and validators, returning a message per problem.
+// Validators that can't be read or compiled are skipped; CheckValidators reports them.
+func (a Attribute) Validate(value gjson.Result) (messages []string) {
+	if message, ok := a.checkDataType(value); !ok {
+		return []string{message}
+	}
+
+	for _, raw := range a.Validators {
+		var v validator
+
+		if json.Unmarshal(raw, &v) != nil {
+			continue
+		}
+
+		if message, ok := v.check(value); !ok {
+			messages = append(messages, message)
+		}
+	}
+
+	return
+}
+
+func (a Attribute) checkDataType(value gjson.Result) (message string, ok bool) {
+	switch a.DataType {
+	case DataTypeInteger:
+		ok = value.Type == gjson.Number && value.Num == math.Trunc(value.Num)
+	case DataTypeDouble:
+		ok = value.Type == gjson.Number
+	case DataTypeBoolean:
+		ok = value.IsBool()
+	case DataTypeString:
+		ok = value.Type == gjson.String
+	case DataTypeDate:
+		ok = value.Type == gjson.String && isDate(value.String())
+	default:
+		ok = true
+	}
+
+	if !ok {
+		message = fmt.Sprintf("expected %s but got %s", a.DataType, value.Raw)
+	}
+
+	return
+}
+
+func (v validator) check(value gjson.Result) (message string, ok bool) {
+	limit := gjson.ParseBytes(v.Value)
+
+	switch strings.ToLower(v.Type) {
+	case ValidatorMin:
+		ok = value.Float() >= limit.Float()
+		message = fmt.Sprintf("must be at least %s but got %s", limit.Raw, value.Raw)
+	case ValidatorMax:
+		ok = value.Float() <= limit.Float()
+		message = fmt.Sprintf("must be at most %s but got %s", limit.Raw, value.Raw)
+	case ValidatorMinLength:
+		ok = int64(utf8.RuneCountInString(value.String())) >= limit.Int()
+		message = fmt.Sprintf("must be at least %s characters long", limit.Raw)
+	case ValidatorMaxLength:
+		ok = int64(utf8.RuneCountInString(value.String())) <= limit.Int()
+		message = fmt.Sprintf("must be at most %s characters long", limit.Raw)
+	case ValidatorPattern:
+		pattern, err := v.pattern()
+		ok = err != nil || pattern.MatchString(value.String())
+		message = fmt.Sprintf("must match the pattern %s", limit.Raw)
+	case ValidatorEnum:
+		for _, allowed := range v.Values {
+			if fmt.Sprint(allowed) == fmt.Sprint(value.Value()) {
+				ok = true
+			}
+		}
+		message = fmt.Sprintf("must be one of %v", v.Values)
+	default:
+		// Validators this CLI doesn't understand are left for CMS to enforce.
+		ok = true
+	}
+
+	return
+}
+
+// Compiles the regular expression of a pattern validator. Other validators have no pattern.
+func (v validator) pattern() (pattern *regexp.Regexp, err error) {
+	if strings.ToLower(v.Type) == ValidatorPattern {
+		pattern, err = regexp.Compile(gjson.ParseBytes(v.Value).String())
+	}
+
+	return
+}
+
+func isDate(value string) bool {
+	for _, format := range dateFormats {
+		if _, err := time.Parse(format, value); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
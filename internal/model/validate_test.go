@@ -0,0 +1,192 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func testType(validators ...string) *Type {
+	attribute := Attribute{Name: "code", DataType: DataTypeString}
+
+	for _, v := range validators {
+		attribute.Validators = append(attribute.Validators, json.RawMessage(v))
+	}
+
+	return &Type{Path: "planet.ottype", Attributes: []Attribute{attribute}}
+}
+
+func TestLengthsCountCharacters(t *testing.T) {
+	planet := testType(`{"type": "min_length", "value": 4}`, `{"type": "max_length", "value": 4}`)
+
+	if violations := planet.ValidateRecords("data.json", gjson.Parse(`[{"name": "a", "code": "Ærøé"}]`)); len(violations) > 0 {
+		t.Errorf("Expected a 4 character value to pass, got %v", violations)
+	}
+
+	if violations := planet.ValidateRecords("data.json", gjson.Parse(`[{"name": "a", "code": "Ærøéx"}]`)); len(violations) != 1 {
+		t.Errorf("Expected a 5 character value to fail max_length, got %v", violations)
+	}
+}
+
+func TestInvalidPatternIsReportedOnce(t *testing.T) {
+	planet := testType(`{"type": "pattern", "value": "[a-"}`)
+	records := gjson.Parse(`[{"name": "a", "code": "x"}, {"name": "b", "code": "y"}, {"name": "c", "code": "z"}]`)
+
+	violations := planet.ValidateRecords("data.json", records)
+
+	if len(violations) != 1 || violations[0].Record != 0 || !strings.Contains(violations[0].String(), "planet.ottype: code: has an invalid pattern") {
+		t.Errorf("Expected a single model violation, got %v", violations)
+	}
+}
+
+func TestPatternIsMatched(t *testing.T) {
+	planet := testType(`{"type": "pattern", "value": "^[A-Z]+$"}`)
+
+	if violations := planet.ValidateRecords("data.json", gjson.Parse(`[{"name": "a", "code": "ABC"}, {"name": "b", "code": "abc"}]`)); len(violations) != 1 || violations[0].Record != 2 {
+		t.Errorf("Expected only the second record to fail, got %v", violations)
+	}
+}
+
+// Validates a single record holding the value under the attribute's name and returns the messages for it.
+func validateValue(attribute Attribute, raw string) (messages []string) {
+	planet := &Type{Path: "planet.ottype", Attributes: []Attribute{attribute}}
+	record := gjson.Parse(`{"name": "Mars", "` + attribute.Name + `": ` + raw + `}`)
+
+	for _, violation := range planet.ValidateRecord("data.json", 1, record) {
+		messages = append(messages, violation.Message)
+	}
+
+	return
+}
+
+func TestDataTypes(t *testing.T) {
+	tests := []struct {
+		dataType string
+		raw      string
+		valid    bool
+	}{
+		{DataTypeInteger, `8`, true},
+		{DataTypeInteger, `8.0`, true},
+		{DataTypeInteger, `8.5`, false},
+		{DataTypeInteger, `"8"`, false},
+		{DataTypeInteger, `true`, false},
+		{DataTypeDouble, `8.5`, true},
+		{DataTypeDouble, `8`, true},
+		{DataTypeDouble, `"8.5"`, false},
+		{DataTypeBoolean, `true`, true},
+		{DataTypeBoolean, `false`, true},
+		{DataTypeBoolean, `"true"`, false},
+		{DataTypeBoolean, `1`, false},
+		{DataTypeString, `"Mars"`, true},
+		{DataTypeString, `4`, false},
+		{DataTypeDate, `"2023-10-19"`, true},
+		{DataTypeDate, `"2023-10-19T14:05:53Z"`, true},
+		{DataTypeDate, `"19/10/2023"`, false},
+		{DataTypeDate, `"2023-02-30"`, false},
+		{DataTypeDate, `20231019`, false},
+	}
+
+	for _, test := range tests {
+		messages := validateValue(Attribute{Name: "value", DataType: test.dataType}, test.raw)
+
+		if valid := len(messages) == 0; valid != test.valid {
+			t.Errorf("%s %s: expected valid to be %t, got %v", test.dataType, test.raw, test.valid, messages)
+		}
+	}
+}
+
+func TestRequiredAttributes(t *testing.T) {
+	planet := &Type{Attributes: []Attribute{
+		{Name: "diameter", DataType: DataTypeInteger, Required: true},
+		{Name: "moons", DataType: DataTypeInteger},
+	}}
+
+	tests := []struct {
+		record     string
+		attributes []string
+	}{
+		{`{"name": "Mars", "diameter": 6779, "moons": 2}`, nil},
+		{`{"name": "Mars", "diameter": 6779}`, nil},
+		{`{"name": "Mars", "moons": 2}`, []string{"diameter"}},
+		{`{"name": "Mars", "diameter": null}`, []string{"diameter"}},
+		{`{"diameter": 6779}`, []string{"name"}},
+		{`{}`, []string{"name", "diameter"}},
+	}
+
+	for _, test := range tests {
+		var attributes []string
+
+		for _, violation := range planet.ValidateRecord("data.json", 1, gjson.Parse(test.record)) {
+			if violation.Message != "is required" {
+				t.Errorf("%s: unexpected violation %s", test.record, violation)
+			}
+
+			attributes = append(attributes, violation.Attribute)
+		}
+
+		if strings.Join(attributes, ",") != strings.Join(test.attributes, ",") {
+			t.Errorf("%s: expected %v to be required, got %v", test.record, test.attributes, attributes)
+		}
+	}
+}
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		dataType  string
+		validator string
+		raw       string
+		message   string
+	}{
+		{DataTypeInteger, `{"type": "min", "value": 0}`, `0`, ""},
+		{DataTypeInteger, `{"type": "min", "value": 0}`, `-1`, "must be at least 0 but got -1"},
+		{DataTypeDouble, `{"type": "max", "value": 1.5}`, `1.5`, ""},
+		{DataTypeDouble, `{"type": "max", "value": 1.5}`, `1.6`, "must be at most 1.5 but got 1.6"},
+		{DataTypeString, `{"type": "MIN_LENGTH", "value": 2}`, `"a"`, "must be at least 2 characters long"},
+		{DataTypeString, `{"type": "enum", "values": ["rocky", "gas"]}`, `"gas"`, ""},
+		{DataTypeString, `{"type": "enum", "values": ["rocky", "gas"]}`, `"ice"`, "must be one of [rocky gas]"},
+		{DataTypeInteger, `{"type": "enum", "values": [1, 2]}`, `2`, ""},
+		{DataTypeInteger, `{"type": "enum", "values": [1, 2]}`, `3`, "must be one of [1 2]"},
+		{DataTypeString, `{"type": "not_understood", "value": 1}`, `"x"`, ""},
+	}
+
+	for _, test := range tests {
+		attribute := Attribute{Name: "value", DataType: test.dataType, Validators: []json.RawMessage{json.RawMessage(test.validator)}}
+		messages := validateValue(attribute, test.raw)
+
+		if got := strings.Join(messages, "; "); got != test.message {
+			t.Errorf("%s with %s: expected %q, got %q", test.validator, test.raw, test.message, got)
+		}
+	}
+}
+
+func TestViolationLocations(t *testing.T) {
+	planet := &Type{Path: "planet.ottype", Attributes: []Attribute{
+		{Name: "diameter", DataType: DataTypeInteger, Required: true},
+		{Name: "code", DataType: DataTypeString, Validators: []json.RawMessage{json.RawMessage(`{"type": "pattern", "value": "("}`)}},
+	}}
+	records := gjson.Parse(`[{"name": "Mercury", "diameter": 4879}, {"name": "Venus", "diameter": "big"}, "Earth"]`)
+
+	tests := []string{
+		"planet.ottype: code: has an invalid pattern validator \"(\": error parsing regexp: missing closing ): `(`",
+		"data.json: record 2 (Venus): diameter: expected integer but got \"big\"",
+		"data.json: record 3: record must be a JSON object",
+	}
+
+	violations := planet.ValidateRecords("data.json", records)
+
+	if len(violations) != len(tests) {
+		t.Fatalf("Expected %d violations, got %v", len(tests), violations)
+	}
+
+	for i, expected := range tests {
+		if got := violations[i].String(); got != expected {
+			t.Errorf("Expected violation %q, got %q", expected, got)
+		}
+	}
+
+	if violations := planet.ValidateRecords("data.json", gjson.Parse(`{"name": "Mars"}`)); violations[len(violations)-1].String() != "data.json: data must be a JSON array of records" {
+		t.Errorf("Expected a violation for data that isn't an array, got %v", violations)
+	}
+}
@@ -2,204 +2,166 @@
 package authutil
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
-	"ocp/sample/planets/internal/config"
 	ioutil "ocp/sample/planets/internal/util/io"
 	logutil "ocp/sample/planets/internal/util/log"
-	"strings"
+	"sync"
 
 	"github.com/tidwall/gjson"
 )
 
-var cachedAccessToken string
-
-// Gets the authentication host from the environment.
-func AuthHost() (authHost string, err error) {
-	var baseUrl string
+// An error caused by OCP refusing to issue an access token
+type AuthError struct {
+	StatusCode int
+	Message    string
+}
 
-	baseUrl, err = config.BaseUrl()
+func (e *AuthError) Error() string {
+	return e.Message
+}
 
-	return fmt.Sprintf("%s/tenants", baseUrl), err
+// Supplies the access tokens sent with CMS requests.
+type TokenSource interface {
+	// The access token to send, fetching a new one when there is none or it is about to expire.
+	Token() (accessToken string, err error)
+	// Drops an access token CMS rejected so the next call to Token fetches a new one.
+	Invalidate(accessToken string)
 }
 
-// Gets the authentication url from the environment.
-func AuthUrl() (url string, err error) {
-	var authHost string
-	var tenantId string
+// Fetches access tokens from the OCP token endpoint with the client's credentials.
+// The token is reused until shortly before it expires, and kept in an on-disk cache between runs when a cache directory is set.
+// Once it expires, a stored refresh token is tried before falling back to the configured grant.
+type OAuthTokenSource struct {
+	http        *ioutil.Client
+	credentials CredentialsFunc
+	cacheDir    string
+	cacheFile   string
+	mu          sync.Mutex
+	token       token
+	// Credentials entered at the prompt are kept so re-authenticating doesn't ask again.
+	promptedUsername string
+	promptedPassword string
+}
 
-	authHost, err = AuthHost()
+// A token source that always returns the same access token, e.g. for tests or a token issued elsewhere.
+type StaticTokenSource string
 
-	if err == nil {
-		tenantId, err = config.TenantId()
-	}
-
-	if err == nil {
-		return fmt.Sprintf("%s/%s/oauth2/token", authHost, tenantId), err
-	}
+// Creates a token source sending token requests through the given client. The credentials are read
+// each time a token is fetched. An empty cache directory disables the on-disk token cache.
+func NewTokenSource(httpClient *ioutil.Client, credentials CredentialsFunc, cacheDir string) *OAuthTokenSource {
+	return &OAuthTokenSource{http: httpClient, credentials: credentials, cacheDir: cacheDir}
+}
 
-	return
+func (s StaticTokenSource) Token() (string, error) {
+	return string(s), nil
 }
 
-// Performs an HTTP request with the OCP authentication token.
-func DoWithToken(url string, method string) (statusCode int, respBody string, err error) {
-	var req *http.Request
+func (s StaticTokenSource) Invalidate(accessToken string) {}
 
-	req, err = ioutil.NewRequest(method, url)
+// Gets the access token, from memory or the on-disk cache when enabled, fetching a new one when required.
+func (s *OAuthTokenSource) Token() (accessToken string, err error) {
+	var creds Credentials
+	var grant grantRequest
 
-	if err == nil {
-		err = AddAuthHeader(req)
-	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if err == nil {
-		statusCode, respBody = ioutil.Do(req, false)
+	if s.token.valid() {
+		return s.token.accessToken, nil
 	}
 
-	return
-}
-
-// Performs an HTTP request with the OCP authentication token and automatically handles retries on failure.
-func DoWithTokenAndRetry(url string, method string) (statusCode int, respBody string, err error) {
-	var req *http.Request
-
-	req, err = ioutil.NewRequest(method, url)
+	creds, err = s.credentials()
 
 	if err == nil {
-		err = AddAuthHeader(req)
+		s.http.Redactor.AddSecret(creds.ClientSecret, creds.Password, creds.RefreshToken)
 	}
 
-	if err == nil {
-		statusCode, respBody = ioutil.Do(req, true)
+	// The cached token belongs to a user, so who that is must be known before it is looked up.
+	if err == nil && creds.GrantType == GRANT_PASSWORD && len(creds.Username) == 0 {
+		creds.Username, err = s.promptUsername()
 	}
 
-	return
-}
-
-// Performs an HTTP request with the OCP authentication token that requires sending a JSON body.
-func DoWithTokenJSONBody(url string, method string, body string) (statusCode int, respBody string, err error) {
-	var req *http.Request
-
-	req, err = ioutil.NewRequestJSONBody(method, url, body)
-
-	if err == nil {
-		setContentType(req)
-		err = AddAuthHeader(req)
-	} else {
-		logutil.LogError(err)
+	if err != nil {
+		return
 	}
 
-	if err == nil {
-		statusCode, respBody = ioutil.Do(req, false)
+	if stored := s.loadCachedToken(creds); stored.valid() || len(stored.refreshToken) > 0 {
+		s.token = stored
 	}
 
-	return
-}
-
-// Adds the authentication token to CMS requests
-func AddAuthHeader(req *http.Request) (err error) {
-	var accessToken string
-
-	accessToken, err = AuthToken()
-
-	if err == nil {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	if s.token.valid() {
+		return s.token.accessToken, nil
 	}
 
-	return
-}
-
-// Fetches the authentication token using the configuration store in the environment.
-func AuthToken() (accessToken string, err error) {
-	if len(cachedAccessToken) > 0 {
-		accessToken = cachedAccessToken
-	} else {
-		var req *http.Request
-		var authUrl string
-		var authBody string
+	if len(s.token.refreshToken) > 0 {
+		accessToken, err = s.requestToken(creds, creds.refreshGrant(s.token.refreshToken))
 
 		if err == nil {
-			authUrl, authBody, err = authConfig()
+			return
 		}
 
-		if err == nil {
-			req, err = ioutil.NewRequestJSONBody(http.MethodPost, authUrl, authBody)
-		}
+		// The rejected refresh token is dropped so it isn't kept, or tried again, once the configured grant succeeds.
+		s.token = token{}
+		s.removeCachedToken()
+		s.http.Logger.Log(logutil.WARN_LEVEL, "Unable to use the stored refresh token, falling back to the configured grant")
+	}
 
-		if err == nil {
-			accessToken, err = fetchAuthToken(req)
-		}
+	grant, err = s.grant(creds)
+
+	if err == nil {
+		accessToken, err = s.requestToken(creds, grant)
 	}
 
 	return
 }
 
-func setContentType(req *http.Request) {
-	req.Header.Set("Content-Type", "application/json")
-}
-
-func InvalidateTokenCache() {
-	cachedAccessToken = ""
-}
+// Builds the token request from the auth URL and a grant and fetches the token.
+func (s *OAuthTokenSource) requestToken(creds Credentials, grant grantRequest) (accessToken string, err error) {
+	var req *http.Request
+	var authBody string
 
-// Gets the authentication URL and the auth body to fetch the token
-func authConfig() (authUrl string, body string, err error) {
-	authUrl, err = AuthUrl()
+	authBody, err = grant.body()
 
 	if err == nil {
-		body, err = authBody()
-	}
-
-	if strings.Contains(authUrl, "replace") || strings.Contains(body, "replace") {
-		err = errors.New("You need to add your tenant id, client id and client secret to your environment.")
-		logutil.LogError(err)
+		req, err = ioutil.NewRequestJSONBody(http.MethodPost, creds.AuthUrl, authBody)
 	}
 
-	return
-}
-
-// Gets the client ID and secret.
-func clientConfig() (confClientId string, clientSecret string, err error) {
-	confClientId, err = config.ConfClientId()
-
 	if err == nil {
-		clientSecret, err = config.ClientSecret()
+		accessToken, err = s.fetchAuthToken(req)
 	}
 
 	return
 }
 
-// Generates the auth body to fetch the token.
-func authBody() (authBody string, err error) {
-	var confClientId string
-	var clientSecret string
+// Fetches an auth token from OCP
+func (s *OAuthTokenSource) fetchAuthToken(req *http.Request) (accessToken string, err error) {
+	var statusCode int
+	var responseBody string
+
+	req.Header.Set("Content-Type", "application/json")
 
-	confClientId, clientSecret, err = clientConfig()
+	s.http.Logger.Log(logutil.INFO_LEVEL, "Fetching access token")
+	statusCode, responseBody, err = s.http.Do(req, false)
 
-	if err == nil {
-		authBody = fmt.Sprintf(`{
-			"client_id": "%s",
-			"client_secret": "%s",
-			"grant_type": "client_credentials"
-		  }`, confClientId, clientSecret)
-	}
+	if err != nil {
+		err = &AuthError{StatusCode: statusCode, Message: fmt.Sprintf("Failed to fetch access token: %s", err)}
+	} else if statusCode < 400 {
+		s.http.Logger.Log(logutil.INFO_LEVEL, "Access token fetched successfully")
+		accessToken = gjson.Get(responseBody, "access_token").String()
+		refreshToken := gjson.Get(responseBody, "refresh_token").String()
 
-	return
-}
+		// Keep the previous refresh token when the token server doesn't issue a new one.
+		if len(refreshToken) == 0 {
+			refreshToken = s.token.refreshToken
+		}
 
-// Fetches an auth token from OCP
-func fetchAuthToken(req *http.Request) (accessToken string, err error) {
-	setContentType(req)
-
-	logutil.Log(logutil.INFO_LEVEL, "Fetching access token")
-	statusCode, responseBody := ioutil.Do(req, false)
-	if statusCode < 400 {
-		logutil.Log(logutil.INFO_LEVEL, "Access token fetched successfully")
-		accessToken = gjson.Get(string(responseBody), "access_token").String()
-		cachedAccessToken = accessToken
+		s.http.Redactor.AddSecret(accessToken, refreshToken)
+		s.token = newToken(accessToken, gjson.Get(responseBody, "expires_in").Int(), refreshToken)
+		s.saveCachedToken(s.token)
 	} else {
-		err = errors.New("Failed to fetch access token")
-		logutil.LogError(err)
+		err = &AuthError{StatusCode: statusCode, Message: "Failed to fetch access token"}
+		s.http.Logger.LogError(err)
 	}
 
 	return
@@ -0,0 +1,159 @@
+package authutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	ioutil "ocp/sample/planets/internal/util/io"
+	logutil "ocp/sample/planets/internal/util/log"
+	"strings"
+	"testing"
+)
+
+// A token endpoint answering each grant type with the response built by the function, counting the grants it receives
+type fakeTokenEndpoint struct {
+	grants  map[string]int
+	respond func(grant grantRequest) (statusCode int, body string)
+}
+
+func (f *fakeTokenEndpoint) RoundTrip(req *http.Request) (*http.Response, error) {
+	var grant grantRequest
+
+	body, _ := io.ReadAll(req.Body)
+	json.Unmarshal(body, &grant)
+	f.grants[grant.GrantType]++
+
+	statusCode, respBody := f.respond(grant)
+
+	return &http.Response{StatusCode: statusCode, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(respBody))}, nil
+}
+
+var testCredentials = Credentials{
+	AuthUrl:      "https://auth.example.com/tenants/t/oauth2/token",
+	ClientId:     "client-id",
+	ClientSecret: "client-secret-value",
+	GrantType:    GRANT_CLIENT_CREDENTIALS,
+}
+
+func newTestTokenSource(respond func(grant grantRequest) (int, string)) (*OAuthTokenSource, *fakeTokenEndpoint) {
+	endpoint := &fakeTokenEndpoint{grants: make(map[string]int), respond: respond}
+	source := NewTokenSource(ioutil.NewClient(endpoint, nil, logutil.Discard, nil), StaticCredentials(testCredentials), "")
+
+	return source, endpoint
+}
+
+func TestTokenIsReused(t *testing.T) {
+	source, endpoint := newTestTokenSource(func(grant grantRequest) (int, string) {
+		return http.StatusOK, `{"access_token":"access-1","expires_in":3600}`
+	})
+
+	for i := 0; i < 3; i++ {
+		if accessToken, err := source.Token(); err != nil || accessToken != "access-1" {
+			t.Fatalf("Expected access-1, got %q, %v", accessToken, err)
+		}
+	}
+
+	if endpoint.grants[GRANT_CLIENT_CREDENTIALS] != 1 {
+		t.Errorf("Expected one token request, got %v", endpoint.grants)
+	}
+}
+
+func TestInvalidatedTokenIsReplaced(t *testing.T) {
+	issued := 0
+	source, _ := newTestTokenSource(func(grant grantRequest) (int, string) {
+		issued++
+		return http.StatusOK, fmt.Sprintf(`{"access_token":"access-%d","expires_in":3600}`, issued)
+	})
+
+	first, _ := source.Token()
+	source.Invalidate("some other token")
+
+	if again, _ := source.Token(); again != first {
+		t.Errorf("Expected a token that wasn't the current one not to be dropped, got %s then %s", first, again)
+	}
+
+	source.Invalidate(first)
+
+	if second, _ := source.Token(); second == first {
+		t.Errorf("Expected a new token after %s was rejected", first)
+	}
+}
+
+func TestRejectedRefreshTokenIsDropped(t *testing.T) {
+	source, endpoint := newTestTokenSource(func(grant grantRequest) (int, string) {
+		if grant.GrantType == GRANT_REFRESH_TOKEN {
+			return http.StatusUnauthorized, `{"error":"invalid_grant"}`
+		}
+
+		return http.StatusOK, `{"access_token":"access-1","expires_in":3600}`
+	})
+	source.token = token{refreshToken: "dead-refresh-token"}
+
+	if accessToken, err := source.Token(); err != nil || accessToken != "access-1" {
+		t.Fatalf("Expected the configured grant to be used, got %q, %v", accessToken, err)
+	}
+
+	if len(source.token.refreshToken) > 0 {
+		t.Errorf("Expected the rejected refresh token to be dropped, kept %s", source.token.refreshToken)
+	}
+
+	if endpoint.grants[GRANT_REFRESH_TOKEN] != 1 || endpoint.grants[GRANT_CLIENT_CREDENTIALS] != 1 {
+		t.Errorf("Expected one refresh and one client credentials grant, got %v", endpoint.grants)
+	}
+}
+
+func TestRejectedCredentialsAreAnAuthError(t *testing.T) {
+	source, _ := newTestTokenSource(func(grant grantRequest) (int, string) {
+		return http.StatusUnauthorized, `{"error":"invalid_client"}`
+	})
+
+	var authErr *AuthError
+
+	_, err := source.Token()
+
+	if !errors.As(err, &authErr) || authErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an *AuthError with status 401, got %v", err)
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	source, _ := newTestTokenSource(func(grant grantRequest) (int, string) {
+		return http.StatusOK, `{"access_token":"access-token-value","refresh_token":"refresh-token-value","expires_in":3600}`
+	})
+
+	source.Token()
+
+	redacted := source.http.Redactor.Redact("client-secret-value access-token-value refresh-token-value")
+
+	if strings.Contains(redacted, "value") {
+		t.Errorf("Expected the credentials and tokens to be masked, got %s", redacted)
+	}
+}
+
+func TestTokenCachePathIsPerUser(t *testing.T) {
+	source := &OAuthTokenSource{cacheDir: t.TempDir()}
+	alice := Credentials{AuthUrl: "https://auth.example.com", ClientId: "client", GrantType: GRANT_PASSWORD, Username: "alice"}
+	bob := alice
+	bob.Username = "bob"
+	service := alice
+	service.GrantType, service.Username = GRANT_CLIENT_CREDENTIALS, ""
+
+	if paths := map[string]bool{source.tokenCachePath(alice): true, source.tokenCachePath(bob): true, source.tokenCachePath(service): true}; len(paths) != 3 {
+		t.Errorf("Expected every user and grant type to have its own cache file")
+	}
+}
+
+func TestShortLivedTokenIsReused(t *testing.T) {
+	source, endpoint := newTestTokenSource(func(grant grantRequest) (int, string) {
+		return http.StatusOK, `{"access_token":"access-1","expires_in":30}`
+	})
+
+	source.Token()
+	source.Token()
+
+	if endpoint.grants[GRANT_CLIENT_CREDENTIALS] != 1 {
+		t.Errorf("Expected a token lasting 30 seconds to be reused, fetched %d", endpoint.grants[GRANT_CLIENT_CREDENTIALS])
+	}
+}
@@ -0,0 +1,156 @@
+package authutil
+
+import (
+	"bufio"
+	"fmt"
+	jsonutil "ocp/sample/planets/internal/util/json"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// The OAuth grant types a token source can use
+const (
+	GRANT_CLIENT_CREDENTIALS = "client_credentials"
+	GRANT_PASSWORD           = "password"
+	GRANT_REFRESH_TOKEN      = "refresh_token"
+)
+
+// An error reporting a credential that isn't configured and can't be prompted for
+type MissingCredentialError struct {
+	Name string
+}
+
+func (e *MissingCredentialError) Error() string {
+	return fmt.Sprintf("The %s is missing and can't be prompted for without a terminal.", e.Name)
+}
+
+// The body of an OAuth token request. Fields not used by the grant type are left out.
+type grantRequest struct {
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	GrantType    string `json:"grant_type"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// What a token source needs to fetch a token. Username and password are used by the password grant and
+// prompted for when empty; the refresh token is used by the refresh token grant.
+type Credentials struct {
+	AuthUrl      string
+	ClientId     string
+	ClientSecret string
+	GrantType    string
+	Username     string
+	Password     string
+	RefreshToken string
+}
+
+// Supplies the credentials when a token is fetched, so they can be read lazily, e.g. from a secret store.
+type CredentialsFunc func() (Credentials, error)
+
+// Always supplies the given credentials.
+func StaticCredentials(creds Credentials) CredentialsFunc {
+	return func() (Credentials, error) {
+		return creds, nil
+	}
+}
+
+// The token request for the configured grant type.
+func (s *OAuthTokenSource) grant(c Credentials) (grant grantRequest, err error) {
+	grant = grantRequest{ClientId: c.ClientId, ClientSecret: c.ClientSecret, GrantType: c.GrantType}
+
+	if len(grant.GrantType) == 0 {
+		grant.GrantType = GRANT_CLIENT_CREDENTIALS
+	}
+
+	switch grant.GrantType {
+	case GRANT_PASSWORD:
+		grant.Username, grant.Password, err = s.userCredentials(c)
+	case GRANT_REFRESH_TOKEN:
+		grant.RefreshToken = c.RefreshToken
+	}
+
+	return
+}
+
+// The token request exchanging a refresh token for a new access token.
+func (c Credentials) refreshGrant(refreshToken string) grantRequest {
+	return grantRequest{ClientId: c.ClientId, ClientSecret: c.ClientSecret, GrantType: GRANT_REFRESH_TOKEN, RefreshToken: refreshToken}
+}
+
+func (g grantRequest) body() (string, error) {
+	return jsonutil.ToJSON(g)
+}
+
+// Gets the username and password for the password grant, prompting for any that aren't configured.
+func (s *OAuthTokenSource) userCredentials(c Credentials) (username string, password string, err error) {
+	username, password = c.Username, c.Password
+
+	if len(username) == 0 {
+		username, err = s.promptUsername()
+	}
+
+	if err == nil && len(password) == 0 {
+		password, err = s.promptPassword(username)
+	}
+
+	return
+}
+
+func (s *OAuthTokenSource) promptUsername() (username string, err error) {
+	if len(s.promptedUsername) > 0 {
+		return s.promptedUsername, nil
+	}
+
+	err = s.requireTerminal("username")
+
+	if err == nil {
+		fmt.Fprint(os.Stderr, "Username: ")
+		username, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		username = strings.TrimSpace(username)
+	}
+
+	if err == nil {
+		s.promptedUsername = username
+	}
+
+	return
+}
+
+// Reads the password without echoing it to the terminal.
+func (s *OAuthTokenSource) promptPassword(username string) (password string, err error) {
+	var passwordBytes []byte
+
+	if len(s.promptedPassword) > 0 {
+		return s.promptedPassword, nil
+	}
+
+	err = s.requireTerminal("password")
+
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+		passwordBytes, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if err == nil {
+		s.promptedPassword = string(passwordBytes)
+		s.http.Redactor.AddSecret(s.promptedPassword)
+		password = s.promptedPassword
+	}
+
+	return
+}
+
+// Prompting only makes sense when someone is at the keyboard; scripts must configure the value instead.
+func (s *OAuthTokenSource) requireTerminal(name string) (err error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		err = &MissingCredentialError{Name: name}
+		s.http.Logger.LogError(err)
+	}
+
+	return
+}
@@ -0,0 +1,77 @@
+package authutil
+
+import (
+	"time"
+)
+
+// Tokens are refreshed this long before they expire so requests in flight don't fail mid-way.
+// Short-lived tokens are refreshed once half their lifetime has passed instead.
+const tokenRefreshMargin = 60 * time.Second
+
+// An access token, the time it expires and the refresh token issued with it, if any.
+// A zero expiry means the token server didn't say.
+type token struct {
+	accessToken   string
+	expiresAt     time.Time
+	refreshMargin time.Duration
+	refreshToken  string
+}
+
+// Creates a token that expires expiresIn seconds from now.
+func newToken(accessToken string, expiresIn int64, refreshToken string) (t token) {
+	t.accessToken = accessToken
+	t.refreshToken = refreshToken
+
+	if expiresIn > 0 {
+		lifetime := time.Duration(expiresIn) * time.Second
+		t.expiresAt = time.Now().Add(lifetime)
+		t.refreshMargin = tokenRefreshMargin
+
+		if t.refreshMargin > lifetime/2 {
+			t.refreshMargin = lifetime / 2
+		}
+	}
+
+	return
+}
+
+// Reports whether the token can still be used without refreshing it first.
+func (t token) valid() bool {
+	if len(t.accessToken) == 0 {
+		return false
+	}
+
+	return t.expiresAt.IsZero() || time.Now().Add(t.margin()).Before(t.expiresAt)
+}
+
+// How long before it expires the token is refreshed.
+func (t token) margin() time.Duration {
+	if t.refreshMargin > 0 {
+		return t.refreshMargin
+	}
+
+	return tokenRefreshMargin
+}
+
+// Clears the token, including the on-disk cache, so the next request fetches a new one.
+func (s *OAuthTokenSource) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token{}
+	s.removeCachedToken()
+}
+
+// Clears the token only if it is the one that was rejected, so concurrent
+// requests failing with the same token don't each fetch a new one.
+func (s *OAuthTokenSource) Invalidate(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.accessToken == accessToken {
+		// The refresh token is still worth trying, so only the access token is dropped.
+		s.token = token{refreshToken: s.token.refreshToken}
+		s.removeCachedToken()
+		s.saveCachedToken(s.token)
+	}
+}
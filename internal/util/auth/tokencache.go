@@ -0,0 +1,133 @@
+package authutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The on-disk form of a cached token
+type tokenFile struct {
+	AccessToken   string        `json:"access_token"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	RefreshMargin time.Duration `json:"refresh_margin,omitempty"`
+	RefreshToken  string        `json:"refresh_token,omitempty"`
+}
+
+// The cache file for the auth URL, which includes the tenant, client id, grant type and username. Each combination
+// gets its own file, so a token issued to one user is never reused by another, named after a hash so the file name
+// doesn't reveal them. Empty when the on-disk cache is disabled.
+func (s *OAuthTokenSource) tokenCachePath(creds Credentials) string {
+	if len(s.cacheDir) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s", creds.AuthUrl, creds.ClientId, creds.GrantType, creds.Username)))
+
+	return filepath.Join(s.cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Reads a token from the on-disk cache. Missing or unreadable entries are ignored, as are
+// expired entries unless they hold a refresh token that can be used to fetch a new access token.
+func (s *OAuthTokenSource) loadCachedToken(creds Credentials) (t token) {
+	var contents []byte
+	var file tokenFile
+	var err error
+
+	s.cacheFile = s.tokenCachePath(creds)
+
+	if len(s.cacheFile) == 0 {
+		return
+	}
+
+	contents, err = os.ReadFile(s.cacheFile)
+
+	if err == nil {
+		err = json.Unmarshal(contents, &file)
+	}
+
+	if err == nil {
+		t = token{accessToken: file.AccessToken, expiresAt: file.ExpiresAt, refreshMargin: file.RefreshMargin, refreshToken: file.RefreshToken}
+		s.http.Redactor.AddSecret(t.accessToken, t.refreshToken)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		s.http.Logger.Log(logutil.WARN_LEVEL, "Ignoring unreadable token cache", "path", s.cacheFile, "error", err)
+	}
+
+	if t.expiresAt.IsZero() {
+		t.accessToken = ""
+	}
+
+	if t.valid() {
+		s.http.Logger.Log(logutil.INFO_LEVEL, "Using cached access token")
+	} else if len(t.refreshToken) == 0 {
+		t = token{}
+	}
+
+	return
+}
+
+// Writes a token to the on-disk cache, readable only by the current user.
+// Tokens without an expiry or a refresh token are never written so a stale token can't be reused forever.
+func (s *OAuthTokenSource) saveCachedToken(t token) {
+	var contents []byte
+	var err error
+
+	if len(s.cacheFile) == 0 || (t.expiresAt.IsZero() && len(t.refreshToken) == 0) {
+		return
+	}
+
+	err = os.MkdirAll(s.cacheDir, 0700)
+
+	if err == nil {
+		contents, err = json.Marshal(tokenFile{AccessToken: t.accessToken, ExpiresAt: t.expiresAt, RefreshMargin: t.refreshMargin, RefreshToken: t.refreshToken})
+	}
+
+	if err == nil {
+		err = writeFileAtomic(s.cacheFile, contents, 0600)
+	}
+
+	if err != nil {
+		s.http.Logger.Log(logutil.WARN_LEVEL, "Unable to write token cache", "path", s.cacheFile, "error", err)
+	}
+}
+
+// Removes the current token from the on-disk cache.
+func (s *OAuthTokenSource) removeCachedToken() {
+	if len(s.cacheFile) > 0 {
+		os.Remove(s.cacheFile)
+	}
+}
+
+// Writes to a temporary file and renames it so concurrent runs never read a half-written token.
+func writeFileAtomic(path string, contents []byte, perm os.FileMode) (err error) {
+	var tmp *os.File
+
+	tmp, err = os.CreateTemp(filepath.Dir(path), ".token-*")
+
+	if err != nil {
+		return
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err = tmp.Chmod(perm); err == nil {
+		_, err = tmp.Write(contents)
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	return
+}
@@ -0,0 +1,253 @@
+package ioutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"sync"
+	"time"
+)
+
+// A request and the response it received, as stored in a cassette file.
+// Bodies and URLs are redacted before they are written, the same way as log output.
+type Interaction struct {
+	Method       string `json:"method"`
+	Url          string `json:"url"`
+	RequestBody  string `json:"request_body,omitempty"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+// Records interactions to a file, one JSON object per line, so a session can be replayed later.
+type recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Serves recorded responses in place of the network. Each interaction is served once,
+// matched on method, path, query and request body, falling back to method, path and query.
+type player struct {
+	mu       sync.Mutex
+	byBody   map[string][]*Interaction
+	byUrl    map[string][]*Interaction
+	consumed map[*Interaction]bool
+}
+
+// Records every request sent by the client, and its response, to the given cassette file, replacing its contents.
+func (c *Client) RecordTo(path string) (err error) {
+	var file *os.File
+
+	file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+
+	if err == nil {
+		c.recorder = &recorder{file: file}
+	} else {
+		c.Logger.LogError(err)
+	}
+
+	return
+}
+
+// Serves every request sent by the client from the given cassette file instead of the network.
+func (c *Client) ReplayFrom(path string) (err error) {
+	var file *os.File
+
+	file, err = os.Open(path)
+
+	if err != nil {
+		c.Logger.LogError(err)
+		return
+	}
+
+	defer file.Close()
+
+	p := &player{
+		byBody:   make(map[string][]*Interaction),
+		byUrl:    make(map[string][]*Interaction),
+		consumed: make(map[*Interaction]bool),
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for line := 1; err == nil && scanner.Scan(); line++ {
+		interaction := &Interaction{}
+
+		if err = json.Unmarshal(scanner.Bytes(), interaction); err == nil {
+			withBody, withoutBody := bodyKey(interaction.Method, interaction.Url, interaction.RequestBody), urlKey(interaction.Method, interaction.Url)
+			p.byBody[withBody] = append(p.byBody[withBody], interaction)
+			p.byUrl[withoutBody] = append(p.byUrl[withoutBody], interaction)
+		} else {
+			err = fmt.Errorf("Invalid interaction on line %d of cassette %s: %s", line, path, err)
+		}
+	}
+
+	if err == nil {
+		err = scanner.Err()
+	}
+
+	if err == nil {
+		c.player = p
+	} else {
+		c.Logger.LogError(err)
+	}
+
+	return
+}
+
+// Reports whether responses are served from a cassette rather than the network.
+func (c *Client) Replaying() bool {
+	return c.player != nil
+}
+
+// Finds the next unused interaction recorded for the request.
+func (p *player) play(method string, url string, body string) (interaction *Interaction, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	interaction = p.next(p.byBody[bodyKey(method, url, body)])
+
+	if interaction == nil {
+		interaction = p.next(p.byUrl[urlKey(method, url)])
+	}
+
+	if interaction == nil {
+		err = fmt.Errorf("No recorded response left for %s %s", method, url)
+		return
+	}
+
+	p.consumed[interaction] = true
+
+	if len(interaction.Error) > 0 {
+		err = errors.New(interaction.Error)
+	}
+
+	return
+}
+
+func (p *player) next(interactions []*Interaction) *Interaction {
+	for _, interaction := range interactions {
+		if !p.consumed[interaction] {
+			return interaction
+		}
+	}
+
+	return nil
+}
+
+// Stops recording, flushing the cassette to disk and closing it. Closing a client that isn't recording does nothing.
+func (c *Client) Close() (err error) {
+	if c.recorder == nil {
+		return
+	}
+
+	err = c.recorder.close()
+	c.recorder = nil
+
+	if err != nil {
+		c.Logger.LogError(err)
+	}
+
+	return
+}
+
+func (r *recorder) close() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = r.file.Sync()
+
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return
+}
+
+func (r *recorder) record(interaction Interaction) (err error) {
+	var line []byte
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line, err = json.Marshal(interaction)
+
+	if err == nil {
+		_, err = r.file.Write(append(line, '\n'))
+	}
+
+	return
+}
+
+// Serves the request from the cassette when replaying.
+func (c *Client) replay(req *http.Request, requestBody string) (response Response, err error) {
+	var interaction *Interaction
+
+	interaction, err = c.player.play(req.Method, c.redactedUrl(req), c.Redactor.Redact(requestBody))
+
+	if err != nil {
+		c.Logger.LogError(err)
+		return
+	}
+
+	c.Logger.Log(logutil.DEBUG_LEVEL, "Replayed HTTP request", "method", req.Method, "url", req.URL.String(), "status", interaction.StatusCode)
+
+	return Response{StatusCode: interaction.StatusCode, Body: interaction.ResponseBody}, err
+}
+
+// Adds the request and its response to the cassette when recording.
+func (c *Client) record(req *http.Request, requestBody string, statusCode int, respBody string, requestErr error, duration time.Duration) {
+	if c.recorder == nil {
+		return
+	}
+
+	interaction := Interaction{
+		Method:       req.Method,
+		Url:          c.redactedUrl(req),
+		RequestBody:  c.Redactor.Redact(requestBody),
+		StatusCode:   statusCode,
+		ResponseBody: c.Redactor.Redact(respBody),
+		DurationMs:   duration.Milliseconds(),
+	}
+
+	if requestErr != nil {
+		interaction.Error = c.Redactor.Redact(requestErr.Error())
+	}
+
+	if err := c.recorder.record(interaction); err != nil {
+		c.Logger.LogError(err)
+	}
+}
+
+// The request body, read without consuming it so the request can still be sent.
+func requestBodyString(req *http.Request) (body string) {
+	if req.GetBody != nil {
+		if reader, err := req.GetBody(); err == nil {
+			bodyBytes, _ := io.ReadAll(reader)
+			body = string(bodyBytes)
+		}
+	}
+
+	return
+}
+
+// The path and query of the request, without the host, so a session recorded against one tenant
+// can be replayed with any base url configured.
+func (c *Client) redactedUrl(req *http.Request) string {
+	return c.Redactor.Redact(req.URL.RequestURI())
+}
+
+func bodyKey(method string, url string, body string) string {
+	return method + " " + url + "\n" + body
+}
+
+func urlKey(method string, url string) string {
+	return method + " " + url
+}
@@ -0,0 +1,130 @@
+package ioutil
+
+import (
+	"io"
+	"net/http"
+	logutil "ocp/sample/planets/internal/util/log"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Sends HTTP requests and reads their responses. The plain and retrying clients are built once and
+// share a transport, so every request, including each retry attempt, waits on the same rate limiter.
+// Requests can also be recorded to a cassette, or served from one instead of the network.
+type Client struct {
+	Logger   logutil.Logger
+	Redactor *logutil.Redactor
+	http     *http.Client
+	retry    *retryablehttp.Client
+	recorder *recorder
+	player   *player
+}
+
+// Creates a client sending requests through the given transport, or http.DefaultTransport when nil.
+// A nil limiter disables rate limiting and a nil logger uses logutil.Default. Everything the client logs or
+// records is masked with the redactor, which is created when nil.
+func NewClient(transport http.RoundTripper, limiter *RateLimiter, logger logutil.Logger, redactor *logutil.Redactor) *Client {
+	if logger == nil {
+		logger = logutil.Default
+	}
+
+	if redactor == nil {
+		redactor = logutil.NewRedactor()
+	}
+
+	logger = logutil.WithRedactor(logger, redactor)
+
+	httpClient := &http.Client{Transport: withRateLimit(transport, limiter)}
+
+	retryClient := retryablehttp.NewClient()
+	retryClient.HTTPClient = httpClient
+	retryClient.Logger = retryLogger{logger: logger}
+	// Hand back the last response when retries run out, so callers see the status and body instead of a bare error.
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+
+	return &Client{Logger: logger, Redactor: redactor, http: httpClient, retry: retryClient}
+}
+
+// A response with its body read into a string
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// Sends a request, retrying temporary failures when asked to, and reads the response body into a string.
+// An error is returned when no response was received, e.g. the connection failed or retries ran out.
+func (c *Client) Do(req *http.Request, withRetry bool) (statusCode int, respBody string, err error) {
+	var resp Response
+
+	resp, err = c.Send(req, withRetry)
+
+	return resp.StatusCode, resp.Body, err
+}
+
+// Sends a request like Do, returning the response headers as well as the status code and body.
+// When recording, each request and its response are added to the cassette; when replaying, they are served from it.
+// Replayed responses have no headers.
+func (c *Client) Send(req *http.Request, withRetry bool) (response Response, err error) {
+	var resp *http.Response
+	var requestBody string
+	var bodyBytes []byte
+
+	if c.recorder != nil || c.player != nil {
+		requestBody = requestBodyString(req)
+	}
+
+	if c.player != nil {
+		return c.replay(req, requestBody)
+	}
+
+	start := time.Now()
+
+	if withRetry {
+		resp, err = c.doWithRetry(req)
+	} else {
+		resp, err = c.http.Do(req)
+	}
+
+	if err != nil {
+		c.Logger.LogError(err, "method", req.Method, "url", req.URL.String())
+		c.record(req, requestBody, 0, "", err, time.Since(start))
+		return
+	}
+
+	defer resp.Body.Close()
+
+	bodyBytes, err = io.ReadAll(resp.Body)
+	response = Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(bodyBytes)}
+
+	if err != nil {
+		c.Logger.LogError(err, "method", req.Method, "url", req.URL.String())
+	}
+
+	c.Logger.Log(logutil.DEBUG_LEVEL, "HTTP request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", time.Since(start))
+	c.logResponseWithError(req, resp, response.Body)
+	c.record(req, requestBody, resp.StatusCode, response.Body, err, time.Since(start))
+
+	return
+}
+
+// If we receive an error status code then log the result
+func (c *Client) logResponseWithError(req *http.Request, resp *http.Response, respBody string) {
+	if resp.StatusCode >= 400 {
+		c.Logger.Log(logutil.ERROR_LEVEL, "HTTP request failed", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "body", respBody)
+	}
+}
+
+// Use the go-retryablehttp module to handle the request
+func (c *Client) doWithRetry(req *http.Request) (resp *http.Response, err error) {
+	var retryableRequest *retryablehttp.Request
+
+	retryableRequest, err = retryablehttp.FromRequest(req)
+
+	if err == nil {
+		return c.retry.Do(retryableRequest)
+	}
+
+	return
+}
@@ -0,0 +1,115 @@
+package ioutil
+
+import (
+	"io"
+	"net/http"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A transport answering requests with the responses built by the function, counting the requests it receives
+type fakeTransport struct {
+	requests int
+	respond  func(attempt int, req *http.Request) *http.Response
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	return f.respond(f.requests, req), nil
+}
+
+func response(statusCode int, body string, header ...string) *http.Response {
+	resp := &http.Response{StatusCode: statusCode, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+
+	for i := 0; i+1 < len(header); i += 2 {
+		resp.Header.Set(header[i], header[i+1])
+	}
+
+	return resp
+}
+
+func TestSendRetriesTemporaryFailures(t *testing.T) {
+	transport := &fakeTransport{respond: func(attempt int, req *http.Request) *http.Response {
+		if attempt == 1 {
+			return response(http.StatusServiceUnavailable, "", "Retry-After", "0")
+		}
+
+		return response(http.StatusOK, `{"ok":true}`, "X-Request-Id", "req-1")
+	}}
+	client := NewClient(transport, nil, logutil.Discard, nil)
+	req, _ := NewRequest(http.MethodGet, "https://cms.example.com/instances")
+
+	resp, err := client.Send(req, true)
+
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Body != `{"ok":true}` {
+		t.Fatalf("Expected the retried request to succeed, got %+v, %v", resp, err)
+	}
+
+	if resp.Header.Get("X-Request-Id") != "req-1" {
+		t.Errorf("Expected the response headers to be returned, got %v", resp.Header)
+	}
+
+	if transport.requests != 2 {
+		t.Errorf("Expected 2 attempts, got %d", transport.requests)
+	}
+}
+
+func TestSendWithoutRetryReturnsFailure(t *testing.T) {
+	transport := &fakeTransport{respond: func(attempt int, req *http.Request) *http.Response {
+		return response(http.StatusServiceUnavailable, `{"message":"down"}`)
+	}}
+	client := NewClient(transport, nil, logutil.Discard, nil)
+	req, _ := NewRequestJSONBody(http.MethodPost, "https://cms.example.com/instances", `{"name":"Mars"}`)
+
+	resp, err := client.Send(req, false)
+
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable || transport.requests != 1 {
+		t.Errorf("Expected a single attempt returning the 503, got %+v, %v after %d attempt(s)", resp, err, transport.requests)
+	}
+}
+
+func TestRecordRedactsSecrets(t *testing.T) {
+	transport := &fakeTransport{respond: func(attempt int, req *http.Request) *http.Response {
+		return response(http.StatusOK, `{"access_token":"token-value","properties":{"code":"1234"}}`)
+	}}
+	redactor := logutil.NewRedactor("properties.code")
+	redactor.AddSecret("client-secret-value")
+	client := NewClient(transport, nil, logutil.Discard, redactor)
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	if err := client.RecordTo(path); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := NewRequestJSONBody(http.MethodPost, "https://auth.example.com/token", `{"secret":"client-secret-value"}`)
+
+	if _, err := client.Send(req, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests sent after closing aren't recorded.
+	req, _ = NewRequestJSONBody(http.MethodPost, "https://auth.example.com/token", `{}`)
+
+	if _, err := client.Send(req, false); err != nil {
+		t.Fatal(err)
+	}
+
+	cassette, _ := os.ReadFile(path)
+
+	if lines := strings.Count(string(cassette), "\n"); lines != 1 {
+		t.Errorf("Expected one recorded interaction, got %d", lines)
+	}
+
+	for _, secret := range []string{"client-secret-value", "token-value", "1234", "auth.example.com"} {
+		if strings.Contains(string(cassette), secret) {
+			t.Errorf("Expected %s to be redacted from the cassette:\n%s", secret, cassette)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ioutil
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CMS allows a maximum of 5 requests per second per tenant
+const DefaultRateLimit = 5.0
+
+// A token bucket shared by every request of a client so batches stay under the CMS rate limit
+// rather than relying on retries once 429 responses start coming back.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tokens   float64
+	last     time.Time
+}
+
+// Creates a limiter allowing requestsPerSecond requests per second with bursts of up to burst requests.
+// A rate of zero or less disables limiting.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		burst:    burst,
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Blocks until the bucket has a token for the next request, or the context is done, in which case the
+// token is handed back and the context's error returned. A nil limiter never blocks.
+func (l *RateLimiter) Wait(ctx context.Context) (err error) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	l.last = now
+
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+
+	// Take the token now, even if that leaves the bucket in debt, so waiting callers queue up in order.
+	l.tokens--
+	wait := time.Duration(-l.tokens * float64(l.interval))
+
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return
+	}
+
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+
+		err = ctx.Err()
+	}
+
+	return
+}
+
+// An http.RoundTripper that waits on a limiter before every attempt, including retries.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// Wraps a transport so every request it sends is rate limited.
+func withRateLimit(base http.RoundTripper, limiter *RateLimiter) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &limitedTransport{base: base, limiter: limiter}
+}
@@ -0,0 +1,49 @@
+package ioutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWaitStopsWhenContextIsDone(t *testing.T) {
+	limiter := NewRateLimiter(0.1, 1)
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Expected the first token without waiting, got %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+	err := limiter.Wait(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(began) > time.Second {
+		t.Errorf("Expected Wait to give up with the context, got %v after %s", err, time.Since(began))
+	}
+
+	// The token taken by the cancelled wait is handed back, so the bucket isn't left further in debt.
+	if limiter.tokens < -0.5 {
+		t.Errorf("Expected the token to be refunded, bucket holds %f", limiter.tokens)
+	}
+}
+
+func TestCancelledRequestIsNotSent(t *testing.T) {
+	base := &fakeTransport{respond: func(attempt int, req *http.Request) *http.Response {
+		return response(http.StatusOK, "")
+	}}
+	transport := withRateLimit(base, NewRateLimiter(0.1, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://cms.example.com", nil)
+
+	transport.RoundTrip(req)
+	cancel()
+
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) || base.requests != 1 {
+		t.Errorf("Expected the cancelled request to fail without being sent, got %v with %d sent", err, base.requests)
+	}
+}
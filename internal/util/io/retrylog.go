@@ -0,0 +1,27 @@
+package ioutil
+
+import (
+	logutil "ocp/sample/planets/internal/util/log"
+)
+
+// Passes the retry client's own logging, e.g. each attempt and why it is retried, to the client's logger
+// so it is filtered, formatted and redacted like everything else.
+type retryLogger struct {
+	logger logutil.Logger
+}
+
+func (l retryLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Log(logutil.ERROR_LEVEL, msg, keyvals...)
+}
+
+func (l retryLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Log(logutil.WARN_LEVEL, msg, keyvals...)
+}
+
+func (l retryLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Log(logutil.INFO_LEVEL, msg, keyvals...)
+}
+
+func (l retryLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Log(logutil.DEBUG_LEVEL, msg, keyvals...)
+}
@@ -0,0 +1,57 @@
+package logutil
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Logs messages with key/value fields. Clients take a Logger so code embedding them decides where their logs go.
+type Logger interface {
+	Log(levelId LogLevelId, message string, keyvals ...any)
+	LogError(err error, keyvals ...any)
+}
+
+// Writes with the level, format and output configured in this package
+var Default Logger = std
+
+// Drops every message
+var Discard Logger = discardLogger{}
+
+type discardLogger struct{}
+
+func (discardLogger) Log(levelId LogLevelId, message string, keyvals ...any) {}
+
+func (discardLogger) LogError(err error, keyvals ...any) {}
+
+// Masks secrets with the redactor before passing messages on to the logger, so a logger supplied by
+// code embedding a client never sees the tokens and secrets the client uses.
+func WithRedactor(logger Logger, redactor *Redactor) Logger {
+	return redactingLogger{logger: logger, redactor: redactor}
+}
+
+type redactingLogger struct {
+	logger   Logger
+	redactor *Redactor
+}
+
+func (l redactingLogger) Log(levelId LogLevelId, message string, keyvals ...any) {
+	l.logger.Log(levelId, l.redactor.Redact(message), l.redactKeyvals(keyvals)...)
+}
+
+func (l redactingLogger) LogError(err error, keyvals ...any) {
+	l.logger.LogError(errors.New(l.redactor.Redact(err.Error())), l.redactKeyvals(keyvals)...)
+}
+
+func (l redactingLogger) redactKeyvals(keyvals []any) []any {
+	redacted := make([]any, len(keyvals))
+
+	for i := range keyvals {
+		if i%2 == 0 {
+			redacted[i] = keyvals[i]
+		} else {
+			redacted[i] = l.redactor.redactValue(fmt.Sprint(keyvals[i-1]), keyvals[i])
+		}
+	}
+
+	return redacted
+}
@@ -2,107 +2,347 @@
 package logutil
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"os"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
-)
 
-type Logger struct {
-	*log.Logger
-}
+	"golang.org/x/term"
+)
 
 type LogLevelId int
 
 type LogLevel struct {
-	name  string
-	level LogLevelId
-	color string
+	name     string
+	level    LogLevelId
+	color    string
+	severity int
 }
 
 const (
 	INFO_LEVEL  LogLevelId = 1
 	ERROR_LEVEL LogLevelId = 2
 	WARN_LEVEL  LogLevelId = 3
+	DEBUG_LEVEL LogLevelId = 4
+
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
 
 	colorRed    string = "\033[31m"
 	colorGreen  string = "\033[32m"
 	colorYellow string = "\033[33m"
+	colorCyan   string = "\033[36m"
 	colorReset  string = "\033[0m"
+)
+
+var logLevels = map[LogLevelId]LogLevel{
+	DEBUG_LEVEL: {name: "DEBUG", level: DEBUG_LEVEL, color: colorCyan, severity: 0},
+	INFO_LEVEL:  {name: "INFO", level: INFO_LEVEL, color: colorGreen, severity: 1},
+	WARN_LEVEL:  {name: "WARN", level: WARN_LEVEL, color: colorYellow, severity: 2},
+	ERROR_LEVEL: {name: "ERROR", level: ERROR_LEVEL, color: colorRed, severity: 3},
+}
 
-	// These constants are used to grab the file name from the stack when making a logger call.
-	// If the code to create or get a logger is changed, these should be examined to ensure they are still the correct values.
+// Functions in this package are skipped when finding the code that made a logger call
+var packagePrefix string
 
-	loggerCreateSkipLevel int = 7
-	loggerGetSkipLevel    int = 3
-)
+func init() {
+	packagePrefix = functionPackage(callerName)
+}
+
+// Writes logs as text or JSON lines to a writer, skipping messages below its level. Each has its own
+// output, format and level, so code embedding a client can configure its logs without touching the cli's.
+type TextLogger struct {
+	mu       sync.Mutex
+	output   io.Writer
+	format   string
+	minLevel LogLevelId
+	color    bool
+}
+
+// The cli's logger, configured with the functions below
+var std = NewTextLogger(os.Stderr)
+
+// Creates a logger writing text at INFO level and above to the writer.
+func NewTextLogger(w io.Writer) *TextLogger {
+	return &TextLogger{output: w, format: FORMAT_TEXT, minLevel: INFO_LEVEL, color: colorSupported(w)}
+}
+
+// Logs a message at the given level with optional key/value pairs, e.g. "id", id, "status", statusCode.
+// Secrets in the message and values are redacted first.
+func Log(levelId LogLevelId, message string, keyvals ...any) {
+	std.write(levelId, message, keyvals)
+}
+
+// Logs an error with optional key/value pairs.
+func LogError(err error, keyvals ...any) {
+	std.write(ERROR_LEVEL, err.Error(), keyvals)
+}
+
+// Reports whether messages at the given level are logged, so expensive messages can be skipped.
+func Enabled(levelId LogLevelId) bool {
+	return std.Enabled(levelId)
+}
+
+// Sets the least severe level that is logged.
+func SetLevel(levelId LogLevelId) {
+	std.SetLevel(levelId)
+}
+
+// Sets the log format: text for people or json for log collectors.
+func SetFormat(logFormat string) error {
+	return std.SetFormat(logFormat)
+}
+
+// Sets where logs are written. Color is only used when writing text to a terminal.
+func SetOutput(w io.Writer) {
+	std.SetOutput(w)
+}
 
-var logLevels = make(map[LogLevelId]LogLevel)
+// Logs a message at the given level with optional key/value pairs.
+func (l *TextLogger) Log(levelId LogLevelId, message string, keyvals ...any) {
+	l.write(levelId, message, keyvals)
+}
+
+// Logs an error with optional key/value pairs.
+func (l *TextLogger) LogError(err error, keyvals ...any) {
+	l.write(ERROR_LEVEL, err.Error(), keyvals)
+}
 
-var cmlogger *Logger
-var once sync.Once
+// Reports whether messages at the given level are logged.
+func (l *TextLogger) Enabled(levelId LogLevelId) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-func Log(levelId LogLevelId, message string) {
-	logger := Get()
-	logLevel := logLevels[levelId]
-	logger.Println(string(logLevel.color), logLevel.name, string(colorReset), message)
+	return logLevels[levelId].severity >= logLevels[l.minLevel].severity
 }
 
-func LogError(err error) {
-	Log(ERROR_LEVEL, err.Error())
+// Sets the least severe level that is logged.
+func (l *TextLogger) SetLevel(levelId LogLevelId) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.minLevel = levelId
 }
 
-func Get() *Logger {
-	once.Do(func() {
-		cmlogger = createLogger()
-		initLevels()
-	})
+// Sets the log format: FORMAT_TEXT or FORMAT_JSON.
+func (l *TextLogger) SetFormat(logFormat string) (err error) {
+	if logFormat != FORMAT_TEXT && logFormat != FORMAT_JSON {
+		return fmt.Errorf("unknown log format %s, expected %s or %s", logFormat, FORMAT_TEXT, FORMAT_JSON)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	cmlogger.SetPrefix(loggerPrefix(loggerGetSkipLevel))
+	l.format = logFormat
 
-	return cmlogger
+	return
 }
 
-func createLogger() *Logger {
-	iow := io.Writer(os.Stdout)
+// Sets where logs are written. Color is only used when writing text to a terminal.
+func (l *TextLogger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	return &Logger{
-		Logger: log.New(iow, loggerPrefix(loggerCreateSkipLevel), log.Lmsgprefix),
+	l.output = w
+	l.color = colorSupported(w)
+}
+
+// The level's name: DEBUG, INFO, WARN or ERROR.
+func (levelId LogLevelId) String() string {
+	return logLevels[levelId].name
+}
+
+// Finds a level by name: debug, info, warn or error.
+func ParseLevel(name string) (levelId LogLevelId, err error) {
+	for id, level := range logLevels {
+		if strings.EqualFold(level.name, name) {
+			return id, nil
+		}
 	}
+
+	err = fmt.Errorf("unknown log level %s, expected debug, info, warn or error", name)
+	return
 }
 
-func initLevels() {
-	logLevels[INFO_LEVEL] = LogLevel{
-		name:  "INFO",
-		level: INFO_LEVEL,
-		color: colorGreen,
+// Wraps text written to stdout in the color used for the given log level, unless stdout isn't a terminal.
+func Colorize(levelId LogLevelId, text string) string {
+	if !colorSupported(os.Stdout) {
+		return text
 	}
 
-	logLevels[ERROR_LEVEL] = LogLevel{
-		name:  "ERROR",
-		level: ERROR_LEVEL,
-		color: colorRed,
+	return logLevels[levelId].color + text + colorReset
+}
+
+// Writes a log line. Only the built-in patterns are masked here; loggers wrapped with a Redactor mask the rest first.
+func (l *TextLogger) write(levelId LogLevelId, message string, keyvals []any) {
+	var redactor *Redactor
+
+	if !l.Enabled(levelId) {
+		return
 	}
 
-	logLevels[WARN_LEVEL] = LogLevel{
-		name:  "WARN",
-		level: WARN_LEVEL,
-		color: colorYellow,
+	level := logLevels[levelId]
+	now := time.Now().UTC()
+	caller := callerName()
+	message = redactor.Redact(message)
+	fields := toFields(redactor, keyvals)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var line []byte
+
+	if l.format == FORMAT_JSON {
+		line = jsonLine(now, level, caller, message, fields)
+	} else {
+		line = textLine(now, level, caller, message, fields, l.color)
 	}
+
+	l.output.Write(line)
 }
 
-func loggerPrefix(skip int) string {
-	dateTime := time.Now().Local().UTC().Format("2006-01-02 15:04:05")
+// A key/value pair attached to a log message
+type field struct {
+	key   string
+	value any
+}
 
-	_, file, line, _ := runtime.Caller(skip)
+// Pairs up keys and values, redacting secrets. A key without a value is logged with the key !MISSING.
+func toFields(redactor *Redactor, keyvals []any) (fields []field) {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 >= len(keyvals) {
+			fields = append(fields, field{key: "!MISSING", value: redactor.redactValue("", keyvals[i])})
+			break
+		}
 
-	directories := strings.Split(file, "/")
+		key := fmt.Sprint(keyvals[i])
+		fields = append(fields, field{key: key, value: redactor.redactValue(key, keyvals[i+1])})
+	}
+
+	return
+}
+
+// Values under a sensitive key are masked; text values are passed through the redaction filter.
+func (r *Redactor) redactValue(key string, value any) any {
+	for _, redactedKey := range redactedKeys {
+		if strings.EqualFold(key, redactedKey) {
+			return REDACTED
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.Redact(v)
+	case error:
+		return r.Redact(v.Error())
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return r.Redact(v.String())
+	}
+
+	return value
+}
+
+func textLine(now time.Time, level LogLevel, caller string, message string, fields []field, color bool) []byte {
+	var b bytes.Buffer
+
+	name := level.name
+	if color {
+		name = level.color + name + colorReset
+	}
+
+	fmt.Fprintf(&b, "%s %s %s %s", now.Format("2006-01-02 15:04:05"), caller, name, message)
+
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%s", f.key, textValue(f.value))
+	}
+
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
+
+// Values with spaces, quotes or equals signs are quoted so lines stay easy to split.
+func textValue(value any) string {
+	s := fmt.Sprint(value)
+
+	if len(s) == 0 || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func jsonLine(now time.Time, level LogLevel, caller string, message string, fields []field) []byte {
+	var b bytes.Buffer
+
+	b.WriteByte('{')
+	writeJSONField(&b, "time", now.Format(time.RFC3339Nano), false)
+	writeJSONField(&b, "level", level.name, true)
+	writeJSONField(&b, "caller", caller, true)
+	writeJSONField(&b, "msg", message, true)
+
+	for _, f := range fields {
+		writeJSONField(&b, f.key, f.value, true)
+	}
+
+	b.WriteString("}\n")
+
+	return b.Bytes()
+}
+
+func writeJSONField(b *bytes.Buffer, key string, value any, comma bool) {
+	if comma {
+		b.WriteByte(',')
+	}
+
+	keyJSON, _ := json.Marshal(key)
+	valueJSON, err := json.Marshal(value)
+
+	if err != nil {
+		valueJSON, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	b.Write(keyJSON)
+	b.WriteByte(':')
+	b.Write(valueJSON)
+}
+
+// The file name and line of the code that made the logger call: the first caller outside this package,
+// so loggers can wrap each other without changing what is reported.
+func callerName() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		if !strings.HasPrefix(frame.Function, packagePrefix) || !more {
+			directories := strings.Split(frame.File, "/")
+
+			return directories[len(directories)-1] + ":" + strconv.Itoa(frame.Line)
+		}
+	}
+}
+
+// The package path of a function followed by a dot, e.g. "ocp/sample/planets/internal/util/log."
+func functionPackage(f any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+
+	return name[:strings.LastIndex(name, ".")+1]
+}
 
-	calledFrom := directories[len(directories)-1]
+// Color is used for terminals only, and never when NO_COLOR is set.
+func colorSupported(w io.Writer) bool {
+	f, ok := w.(*os.File)
 
-	return dateTime + " " + calledFrom + ":" + strconv.Itoa(line)
+	return ok && len(os.Getenv("NO_COLOR")) == 0 && term.IsTerminal(int(f.Fd()))
 }
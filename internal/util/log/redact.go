@@ -0,0 +1,194 @@
+package logutil
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// Replaces every secret masked in log output
+const REDACTED = "********"
+
+// Secrets shorter than this are not masked by value, as masking them would mangle unrelated text
+const minSecretLength = 4
+
+// Keys whose values are masked wherever they appear as JSON fields or form and query parameters
+var redactedKeys = []string{"access_token", "refresh_token", "id_token", "client_secret", "password"}
+
+var (
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+	jsonPattern   = regexp.MustCompile(`(?i)("(?:` + strings.Join(redactedKeys, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	formPattern   = regexp.MustCompile(`(?i)((?:^|[?&\s])(?:` + strings.Join(redactedKeys, "|") + `)=)[^&\s]*`)
+)
+
+// Masks secrets in text before it is logged or recorded: bearer tokens, sensitive JSON fields and form
+// parameters, plus the secret values and JSON paths added to it. Each client has its own, so what one
+// masks doesn't leak into another. A nil Redactor masks only the built-in patterns.
+type Redactor struct {
+	mu      sync.Mutex
+	paths   []string
+	secrets map[string]bool
+}
+
+// Creates a redactor masking the values at the given gjson paths as well as the built-in patterns.
+func NewRedactor(paths ...string) *Redactor {
+	r := &Redactor{secrets: make(map[string]bool)}
+	r.AddPaths(paths...)
+
+	return r
+}
+
+// Masks the values at the given gjson paths, e.g. "properties.code" or "_embedded.collection.#.properties.code",
+// in any JSON redacted from now on.
+func (r *Redactor) AddPaths(paths ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); len(path) > 0 {
+			r.paths = append(r.paths, path)
+		}
+	}
+}
+
+// Masks the given secret values, such as a client secret or access token, wherever they appear from now on.
+func (r *Redactor) AddSecret(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			r.secrets[secret] = true
+		}
+	}
+}
+
+// Masks known secret values, bearer tokens, sensitive JSON fields and form parameters, and the configured JSON paths in a message.
+func (r *Redactor) Redact(message string) string {
+	var secrets []string
+	var paths []string
+
+	if r != nil {
+		r.mu.Lock()
+		for secret := range r.secrets {
+			secrets = append(secrets, secret)
+		}
+		paths = r.paths
+		r.mu.Unlock()
+	}
+
+	// Longer secrets first, so a secret containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	for _, secret := range secrets {
+		message = strings.ReplaceAll(message, secret, REDACTED)
+	}
+
+	message = bearerPattern.ReplaceAllString(message, "${1}"+REDACTED)
+	message = jsonPattern.ReplaceAllString(message, `${1}"`+REDACTED+`"`)
+	message = formPattern.ReplaceAllString(message, "${1}"+REDACTED)
+
+	if len(paths) > 0 {
+		message = redactPaths(message, paths)
+	}
+
+	return message
+}
+
+// Masks the configured paths in the JSON document found at the end of a message, e.g. a logged response body.
+func redactPaths(message string, paths []string) string {
+	start := jsonStart(message)
+
+	if start < 0 {
+		return message
+	}
+
+	json := message[start:]
+
+	type span struct{ start, end int }
+	var spans []span
+
+	for _, path := range paths {
+		result := gjson.Get(json, path)
+
+		if result.Indexes != nil {
+			for i, value := range result.Array() {
+				if i < len(result.Indexes) && result.Indexes[i] > 0 {
+					spans = append(spans, span{result.Indexes[i], result.Indexes[i] + len(value.Raw)})
+				}
+			}
+		} else if result.Index > 0 {
+			spans = append(spans, span{result.Index, result.Index + len(result.Raw)})
+		}
+	}
+
+	// Replace from the end so earlier offsets stay valid.
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+
+	end := len(json) + 1
+	for _, s := range spans {
+		if s.end <= end {
+			json = json[:s.start] + `"` + REDACTED + `"` + json[s.end:]
+			end = s.start
+		}
+	}
+
+	return message[:start] + json
+}
+
+// The offset of the '{' or '[' that begins a valid JSON document running to the end of the message, or -1.
+// The message is scanned once, backwards from its last bracket to the one opening it, and only that
+// candidate is parsed, so long messages that aren't JSON stay cheap.
+func jsonStart(message string) int {
+	end := len(strings.TrimRight(message, " \t\r\n"))
+	var closers []byte
+
+	for i := end - 1; i >= 0; i-- {
+		switch c := message[i]; c {
+		case '}', ']':
+			closers = append(closers, c)
+		case '{', '[':
+			if len(closers) == 0 || closers[len(closers)-1] != c+2 {
+				return -1
+			}
+
+			closers = closers[:len(closers)-1]
+
+			if len(closers) == 0 {
+				if gjson.Valid(message[i:]) {
+					return i
+				}
+
+				return -1
+			}
+		case '"':
+			if len(closers) == 0 {
+				return -1
+			}
+
+			// Skip back over the string to its opening quote.
+			for i--; i >= 0 && (message[i] != '"' || escaped(message, i)); i-- {
+			}
+		default:
+			if len(closers) == 0 {
+				return -1
+			}
+		}
+	}
+
+	return -1
+}
+
+// Reports whether the character at i is escaped by an odd number of backslashes before it.
+func escaped(message string, i int) bool {
+	backslashes := 0
+
+	for i--; i >= 0 && message[i] == '\\'; i-- {
+		backslashes++
+	}
+
+	return backslashes%2 == 1
+}
@@ -0,0 +1,55 @@
+package logutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJsonStart(t *testing.T) {
+	tests := []struct {
+		message string
+		start   int
+	}{
+		{`{"a":1}`, 0},
+		{`body: {"a":[1,{"b":"}"}]}`, 6},
+		{`body: [{"a":"x\"]"}] `, 6},
+		{`got [1] then [2]`, 13},
+		{`escaped {"a":"\\"}`, 8},
+		{`not json {"a":1} here`, -1},
+		{`mismatched {"a":1]`, -1},
+		{`unbalanced "a":1}`, -1},
+		{`a string "[1]"`, -1},
+		{`no brackets`, -1},
+		{``, -1},
+	}
+
+	for _, test := range tests {
+		if start := jsonStart(test.message); start != test.start {
+			t.Errorf("jsonStart(%q) = %d, expected %d", test.message, start, test.start)
+		}
+	}
+}
+
+func TestRedactPathsInBody(t *testing.T) {
+	redactor := NewRedactor("properties.code")
+	message := redactor.Redact(`HTTP response body: {"name":"Earth","properties":{"code":"E-1"}}`)
+
+	if message != `HTTP response body: {"name":"Earth","properties":{"code":"`+REDACTED+`"}}` {
+		t.Errorf("Unexpected redacted message: %s", message)
+	}
+}
+
+func TestRedactLargeMessage(t *testing.T) {
+	redactor := NewRedactor("properties.code")
+	message := strings.Repeat(`{"a":[`, 20000) + " truncated"
+	began := time.Now()
+
+	if redacted := redactor.Redact(message); redacted != message {
+		t.Error("Expected a message without a JSON body to be left alone")
+	}
+
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("Expected a large message to be redacted quickly, took %s", elapsed)
+	}
+}
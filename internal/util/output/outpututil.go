@@ -0,0 +1,363 @@
+// The outpututil package prints command results to stdout in a format chosen by the user,
+// keeping them apart from logs so they can be piped into other tools.
+package outpututil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FORMAT_TABLE  = "table"
+	FORMAT_JSON   = "json"
+	FORMAT_YAML   = "yaml"
+	FORMAT_CSV    = "csv"
+	FORMAT_NDJSON = "ndjson"
+)
+
+var Formats = []string{FORMAT_TABLE, FORMAT_JSON, FORMAT_YAML, FORMAT_CSV, FORMAT_NDJSON}
+
+// How results are printed. Fields are gjson paths into each record, e.g. name or properties.diameter.
+// A field without a dot that isn't found on the record is looked up in its properties, so diameter works too.
+type Options struct {
+	Format string
+	Fields []string
+}
+
+var options = Options{Format: FORMAT_TABLE}
+var output io.Writer = os.Stdout
+
+// Sets the format and fields used by every printer.
+func SetOptions(opts Options) (err error) {
+	for _, format := range Formats {
+		if opts.Format == format {
+			options = opts
+			return
+		}
+	}
+
+	return fmt.Errorf("unknown output format %s, expected one of %s", opts.Format, strings.Join(Formats, ", "))
+}
+
+// Sets where results are printed.
+func SetOutput(w io.Writer) {
+	output = w
+}
+
+// Prints records one at a time. Streaming formats write each record as it arrives;
+// tables are written on Close as every row is needed to size the columns.
+type Printer struct {
+	format        string
+	fields        []string
+	defaultFields []string
+	w             *bufio.Writer
+	csv           *csv.Writer
+	rows          [][]string
+	count         int
+}
+
+// Creates a printer using the configured options. The default fields are the table and CSV columns used
+// when --fields isn't given; with none, the columns are the id, type, name and properties of the first record.
+// JSON and YAML print whole records unless --fields is given.
+func NewPrinter(defaultFields ...string) *Printer {
+	return &Printer{
+		format:        options.Format,
+		fields:        options.Fields,
+		defaultFields: defaultFields,
+		w:             bufio.NewWriter(output),
+	}
+}
+
+// Prints a single record.
+func (p *Printer) Print(record gjson.Result) (err error) {
+	switch p.format {
+	case FORMAT_JSON:
+		err = p.printJSON(record)
+	case FORMAT_NDJSON:
+		err = p.printNDJSON(record)
+	case FORMAT_YAML:
+		err = p.printYAML(record)
+	case FORMAT_CSV:
+		err = p.printCSV(record)
+	default:
+		p.addRow(record)
+	}
+
+	p.count++
+
+	return
+}
+
+// Finishes the output, e.g. closing the JSON array or writing the table, and flushes it.
+func (p *Printer) Close() (err error) {
+	switch p.format {
+	case FORMAT_JSON:
+		if p.count == 0 {
+			_, err = fmt.Fprintln(p.w, "[]")
+		} else {
+			_, err = fmt.Fprintln(p.w, "\n]")
+		}
+	case FORMAT_CSV:
+		if p.csv != nil {
+			p.csv.Flush()
+			err = p.csv.Error()
+		}
+	case FORMAT_TABLE:
+		err = p.writeTable()
+	}
+
+	if err == nil {
+		err = p.w.Flush()
+	}
+
+	return
+}
+
+// Pretty printed elements of a JSON array, streamed as they arrive
+func (p *Printer) printJSON(record gjson.Result) (err error) {
+	var indented []byte
+	var buf strings.Builder
+
+	separator := "[\n"
+	if p.count > 0 {
+		separator = ",\n"
+	}
+
+	indented, err = indentJSON(p.selected(record).Raw)
+
+	if err == nil {
+		buf.WriteString(separator)
+		buf.WriteString("  ")
+		buf.WriteString(strings.ReplaceAll(string(indented), "\n", "\n  "))
+		_, err = p.w.WriteString(buf.String())
+	}
+
+	return
+}
+
+// One compact JSON object per line
+func (p *Printer) printNDJSON(record gjson.Result) (err error) {
+	var buf bytes.Buffer
+
+	err = json.Compact(&buf, []byte(p.selected(record).Raw))
+
+	if err == nil {
+		buf.WriteByte('\n')
+		_, err = p.w.Write(buf.Bytes())
+	}
+
+	return
+}
+
+// A YAML sequence, one item per record, keeping the order of the fields
+func (p *Printer) printYAML(record gjson.Result) (err error) {
+	var doc []byte
+
+	doc, err = yaml.Marshal([]*yaml.Node{yamlNode(p.selected(record))})
+
+	if err == nil {
+		_, err = p.w.Write(doc)
+	}
+
+	return
+}
+
+func (p *Printer) printCSV(record gjson.Result) (err error) {
+	if p.csv == nil {
+		p.csv = csv.NewWriter(p.w)
+		err = p.csv.Write(p.columns(record))
+	}
+
+	if err == nil {
+		err = p.csv.Write(p.cells(record))
+	}
+
+	return
+}
+
+func (p *Printer) addRow(record gjson.Result) {
+	if p.rows == nil {
+		p.rows = append(p.rows, headings(p.columns(record)))
+	}
+
+	p.rows = append(p.rows, p.cells(record))
+}
+
+func (p *Printer) writeTable() (err error) {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+
+	for _, row := range p.rows {
+		_, err = fmt.Fprintln(tw, strings.Join(row, "\t"))
+
+		if err != nil {
+			return
+		}
+	}
+
+	return tw.Flush()
+}
+
+// The fields printed as columns, chosen from the first record when nothing else says otherwise.
+func (p *Printer) columns(record gjson.Result) []string {
+	if len(p.fields) > 0 {
+		return p.fields
+	}
+
+	if len(p.defaultFields) == 0 {
+		p.defaultFields = []string{"id", "type", "name"}
+
+		record.Get("properties").ForEach(func(key, _ gjson.Result) bool {
+			p.defaultFields = append(p.defaultFields, key.String())
+			return true
+		})
+	}
+
+	return p.defaultFields
+}
+
+func (p *Printer) cells(record gjson.Result) (cells []string) {
+	for _, field := range p.columns(record) {
+		value := lookup(record, field)
+
+		if value.IsObject() || value.IsArray() {
+			cells = append(cells, value.Raw)
+		} else {
+			cells = append(cells, value.String())
+		}
+	}
+
+	return
+}
+
+// The record reduced to the selected fields, in the order given. Whole records are used when no fields are selected.
+func (p *Printer) selected(record gjson.Result) gjson.Result {
+	if len(p.fields) == 0 {
+		return record
+	}
+
+	var buf strings.Builder
+
+	buf.WriteByte('{')
+
+	for i, field := range p.fields {
+		key, _ := json.Marshal(field)
+		value := lookup(record, field)
+
+		raw := value.Raw
+		if !value.Exists() {
+			raw = "null"
+		}
+
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.WriteString(raw)
+	}
+
+	buf.WriteByte('}')
+
+	return gjson.Parse(buf.String())
+}
+
+// Gets a field from a record, falling back to the record's properties for a bare name.
+func lookup(record gjson.Result, field string) gjson.Result {
+	value := record.Get(field)
+
+	if !value.Exists() && !strings.ContainsAny(field, ".#|*?") {
+		value = record.Get("properties." + field)
+	}
+
+	return value
+}
+
+// Column headings shown in upper case, using the last part of a path, e.g. DIAMETER for properties.diameter.
+func headings(fields []string) (headings []string) {
+	for _, field := range fields {
+		parts := strings.Split(field, ".")
+		headings = append(headings, strings.ToUpper(parts[len(parts)-1]))
+	}
+
+	return
+}
+
+func indentJSON(raw string) (indented []byte, err error) {
+	var buf bytes.Buffer
+
+	err = json.Indent(&buf, []byte(raw), "", "  ")
+
+	return buf.Bytes(), err
+}
+
+// Converts JSON to a YAML node, keeping the order of object keys.
+func yamlNode(value gjson.Result) *yaml.Node {
+	switch {
+	case value.IsObject():
+		node := &yaml.Node{Kind: yaml.MappingNode}
+
+		value.ForEach(func(key, val gjson.Result) bool {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key.String()}, yamlNode(val))
+			return true
+		})
+
+		return node
+	case value.IsArray():
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+
+		value.ForEach(func(_, val gjson.Result) bool {
+			node.Content = append(node.Content, yamlNode(val))
+			return true
+		})
+
+		return node
+	case value.Type == gjson.String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.String()}
+	case value.Type == gjson.Number:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: value.Raw}
+	case value.Type == gjson.True || value.Type == gjson.False:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: value.Raw}
+	}
+
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// Prints a single record, e.g. one fetched by id. JSON is printed as an object rather than an array.
+func PrintRecord(record gjson.Result, defaultFields ...string) (err error) {
+	p := NewPrinter(defaultFields...)
+
+	if p.format == FORMAT_JSON {
+		var indented []byte
+
+		indented, err = indentJSON(p.selected(record).Raw)
+
+		if err == nil {
+			_, err = fmt.Fprintln(p.w, string(indented))
+		}
+
+		if err == nil {
+			err = p.w.Flush()
+		}
+
+		return
+	}
+
+	err = p.Print(record)
+
+	if err == nil {
+		err = p.Close()
+	}
+
+	return
+}
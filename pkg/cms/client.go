@@ -0,0 +1,229 @@
+// The cms package is a Go client for the OpenText Content Metadata Service. It lists, gets, creates, updates,
+// patches and deletes instances of any category and type, paging through collections as it goes.
+package cms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	authutil "ocp/sample/planets/internal/util/auth"
+	ioutil "ocp/sample/planets/internal/util/io"
+	logutil "ocp/sample/planets/internal/util/log"
+	"os"
+	"strings"
+)
+
+// CMS allows a maximum of 5 requests per second per tenant
+const DefaultRateLimit = ioutil.DefaultRateLimit
+
+// The status code reported for mutating requests that were skipped in dry-run mode
+const DryRunStatusCode = http.StatusOK
+
+// Supplies the access tokens sent with CMS requests
+type TokenSource = authutil.TokenSource
+
+// A token source that always returns the same access token, e.g. one issued elsewhere
+type StaticTokenSource = authutil.StaticTokenSource
+
+// What is needed to fetch access tokens from OCP: the token URL, client id and secret, and the grant to use
+type Credentials = authutil.Credentials
+
+// Supplies the credentials when a token is fetched, so they can be read lazily, e.g. from a secret store
+type CredentialsFunc = authutil.CredentialsFunc
+
+// Receives the client's logs
+type Logger = logutil.Logger
+
+// The level a message is logged at. Its String method gives the level's name.
+type LogLevel = logutil.LogLevelId
+
+// The levels messages are logged at
+const (
+	LevelDebug LogLevel = logutil.DEBUG_LEVEL
+	LevelInfo  LogLevel = logutil.INFO_LEVEL
+	LevelWarn  LogLevel = logutil.WARN_LEVEL
+	LevelError LogLevel = logutil.ERROR_LEVEL
+)
+
+// The formats a TextLogger writes
+const (
+	LogFormatText = logutil.FORMAT_TEXT
+	LogFormatJSON = logutil.FORMAT_JSON
+)
+
+// Writes logs as text or JSON lines, with its own output, format and level
+type TextLogger = logutil.TextLogger
+
+// Writes logs to stderr as text at LevelInfo and above. Change its level, format or output with
+// SetLevel, SetFormat and SetOutput, or give the client another Logger.
+var DefaultLogger = NewTextLogger(os.Stderr)
+
+// Drops every log message
+var DiscardLogger Logger = logutil.Discard
+
+// Creates a logger writing text at LevelInfo and above to the writer.
+func NewTextLogger(w io.Writer) *TextLogger {
+	return logutil.NewTextLogger(w)
+}
+
+// Always supplies the given credentials.
+func StaticCredentials(creds Credentials) CredentialsFunc {
+	return authutil.StaticCredentials(creds)
+}
+
+// How a client connects to CMS. Only BaseUrl and either Tokens or Credentials are required.
+type Options struct {
+	// The OCP base url, e.g. https://na-1-dev.api.opentext.com
+	BaseUrl string
+	// Sends the HTTP requests, http.DefaultTransport when nil. Tests can supply a fake.
+	Transport http.RoundTripper
+	// The maximum requests per second sent to OCP, shared by every request of the client. Zero disables limiting.
+	RateLimit float64
+	// Supplies access tokens. When nil, tokens are fetched from OCP with the Credentials.
+	Tokens TokenSource
+	// Used to fetch tokens when Tokens is nil
+	Credentials CredentialsFunc
+	// Caches tokens fetched with the Credentials between runs. Empty disables the on-disk cache.
+	TokenCacheDir string
+	// Receives the client's logs, DefaultLogger when nil. Tokens and credentials are masked before they reach it.
+	Logger Logger
+	// gjson paths, e.g. "properties.code", whose values are masked in logged and recorded JSON
+	RedactPaths []string
+	// Logs create, update, patch and delete requests instead of sending them
+	DryRun bool
+	// How collections are paged through when listing instances
+	PageOptions PageOptions
+}
+
+// A CMS client holding everything needed to send requests: the HTTP client with its transport and
+// rate limiter, the token source and the logger. Create one with NewClient and share it between goroutines.
+type Client struct {
+	Tokens      TokenSource
+	Logger      Logger
+	http        *ioutil.Client
+	cmsHost     string
+	dryRun      bool
+	pageOptions PageOptions
+}
+
+// Creates a client from the options.
+func NewClient(opts Options) (client *Client, err error) {
+	if _, err = url.ParseRequestURI(opts.BaseUrl); err != nil {
+		return nil, fmt.Errorf("Invalid CMS base url %q: %s", opts.BaseUrl, err)
+	}
+
+	if opts.Tokens == nil && opts.Credentials == nil {
+		return nil, errors.New("A CMS client needs a token source or credentials")
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = DefaultLogger
+	}
+
+	if opts.PageOptions.PageSize <= 0 {
+		opts.PageOptions.PageSize = DefaultPageSize
+	}
+
+	httpClient := ioutil.NewClient(opts.Transport, ioutil.NewRateLimiter(opts.RateLimit, 1), opts.Logger, logutil.NewRedactor(opts.RedactPaths...))
+
+	client = &Client{
+		Tokens:      opts.Tokens,
+		Logger:      httpClient.Logger,
+		http:        httpClient,
+		cmsHost:     strings.TrimSuffix(opts.BaseUrl, "/") + "/cms",
+		dryRun:      opts.DryRun,
+		pageOptions: opts.PageOptions,
+	}
+
+	if client.Tokens == nil {
+		client.Tokens = authutil.NewTokenSource(client.http, opts.Credentials, opts.TokenCacheDir)
+	}
+
+	return
+}
+
+// Reports whether mutating requests are logged instead of sent.
+func (c *Client) DryRun() bool {
+	return c.dryRun
+}
+
+// Records every request sent by the client, and its response, to the given cassette file, replacing its contents.
+// Secrets are redacted before they are written.
+func (c *Client) RecordTo(path string) error {
+	return c.http.RecordTo(path)
+}
+
+// Serves every request sent by the client from a cassette file written by RecordTo instead of the network.
+func (c *Client) ReplayFrom(path string) error {
+	return c.http.ReplayFrom(path)
+}
+
+// Stops recording to the cassette given to RecordTo, flushing and closing it. Requests sent afterwards aren't recorded.
+func (c *Client) Close() error {
+	return c.http.Close()
+}
+
+// Sends a CMS request with the access token and returns the response status and body, or an *APIError when CMS
+// answers with an error status. In dry-run mode, requests that would change data are logged instead of sent
+// and DryRunStatusCode is returned. The status is 0 when no response was received.
+// GET requests and deletes are retried on temporary failures. If CMS rejects the access token a new one
+// is fetched and the request replayed once.
+func (c *Client) send(ctx context.Context, method string, url string, body string) (statusCode int, respBody string, err error) {
+	var accessToken string
+	var resp ioutil.Response
+
+	if c.dryRun && method != http.MethodGet && method != http.MethodHead {
+		c.Logger.Log(logutil.WARN_LEVEL, "[DRY RUN] Request not sent", "method", method, "url", url, "body", body)
+		return DryRunStatusCode, "", nil
+	}
+
+	accessToken, resp, err = c.sendWithToken(ctx, method, url, body)
+
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		c.Logger.Log(logutil.WARN_LEVEL, "Access token was rejected, fetching a new token and replaying the request")
+		c.Tokens.Invalidate(accessToken)
+
+		_, resp, err = c.sendWithToken(ctx, method, url, body)
+	}
+
+	// The failed response has already been logged.
+	if err == nil && resp.StatusCode >= 400 {
+		err = newAPIError(method, url, resp.StatusCode, resp.Header, resp.Body)
+	}
+
+	return resp.StatusCode, resp.Body, err
+}
+
+func (c *Client) sendWithToken(ctx context.Context, method string, url string, body string) (accessToken string, resp ioutil.Response, err error) {
+	var req *http.Request
+
+	accessToken, err = c.Tokens.Token()
+
+	if err != nil {
+		return
+	}
+
+	if len(body) > 0 {
+		req, err = ioutil.NewRequestJSONBody(method, url, body)
+	} else {
+		req, err = ioutil.NewRequest(method, url)
+	}
+
+	if err == nil {
+		req = req.WithContext(ctx)
+
+		if len(body) > 0 {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+		resp, err = c.http.Send(req, len(body) == 0)
+	} else {
+		c.Logger.LogError(err)
+	}
+
+	return
+}
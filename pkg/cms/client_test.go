@@ -0,0 +1,116 @@
+package cms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// A token source handing out numbered tokens, counting how often a token is rejected
+type countingTokenSource struct {
+	issued      int
+	invalidated int
+}
+
+func (s *countingTokenSource) Token() (string, error) {
+	if s.issued == s.invalidated {
+		s.issued++
+	}
+
+	return fmt.Sprintf("token-%d", s.issued), nil
+}
+
+func (s *countingTokenSource) Invalidate(accessToken string) {
+	s.invalidated++
+}
+
+func TestRejectedTokenIsReplacedOnce(t *testing.T) {
+	var authorizations []string
+
+	tokens := &countingTokenSource{}
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+
+		if len(authorizations) == 1 {
+			return jsonResponse(http.StatusUnauthorized, `{"message":"expired"}`)
+		}
+
+		return jsonResponse(http.StatusOK, `{"id":"1","name":"Mars"}`)
+	})
+	client.Tokens = tokens
+
+	instance, err := client.Get(context.Background(), "object", "un_planet", "1")
+
+	if err != nil || instance.Name != "Mars" {
+		t.Fatalf("Expected the replayed request to succeed, got %+v, %v", instance, err)
+	}
+
+	if tokens.invalidated != 1 || len(authorizations) != 2 || authorizations[0] == authorizations[1] {
+		t.Errorf("Expected the request to be replayed once with a new token, sent %v", authorizations)
+	}
+}
+
+func TestDryRunSendsNoChanges(t *testing.T) {
+	requests := 0
+	client, err := NewClient(Options{
+		BaseUrl: "https://cms.example.com",
+		Transport: fakeTransport(func(req *http.Request) *http.Response {
+			requests++
+			return jsonResponse(http.StatusOK, `{}`)
+		}),
+		Tokens: StaticTokenSource("test-token"),
+		Logger: DiscardLogger,
+		DryRun: true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client.Create(ctx, "object", "un_planet", "Mars", nil)
+	client.Patch(ctx, "object", "un_planet", "1", InstancePatch{Name: "Ares"})
+	client.Delete(ctx, "object", "un_planet", "1")
+
+	if requests != 0 {
+		t.Errorf("Expected no requests in dry-run mode, sent %d", requests)
+	}
+}
+
+func TestWritesReturnTheStatusCMSAnswered(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		switch req.Method {
+		case http.MethodPost:
+			return jsonResponse(http.StatusOK, `{"id":"1","name":"Mars"}`)
+		case http.MethodDelete:
+			return jsonResponse(http.StatusAccepted, ``)
+		}
+
+		return jsonResponse(http.StatusConflict, `{"message":"Stale"}`)
+	})
+
+	ctx := context.Background()
+
+	if instance, status, err := client.CreateWithStatus(ctx, "object", "un_planet", "Mars", nil); err != nil || status != http.StatusOK || instance.Id != "1" {
+		t.Errorf("Expected the created instance with status 200, got %+v, %d, %v", instance, status, err)
+	}
+
+	if status, err := client.DeleteWithStatus(ctx, "object", "un_planet", "1"); err != nil || status != http.StatusAccepted {
+		t.Errorf("Expected delete to return status 202, got %d, %v", status, err)
+	}
+
+	if _, status, err := client.UpdateWithStatus(ctx, "object", "un_planet", "1", "Mars", nil); err == nil || status != http.StatusConflict {
+		t.Errorf("Expected update to fail with status 409, got %d, %v", status, err)
+	}
+}
+
+func TestNewClientValidatesOptions(t *testing.T) {
+	if _, err := NewClient(Options{BaseUrl: "not a url", Tokens: StaticTokenSource("t")}); err == nil {
+		t.Error("Expected an invalid base url to be rejected")
+	}
+
+	if _, err := NewClient(Options{BaseUrl: "https://cms.example.com"}); err == nil {
+		t.Error("Expected a client without tokens or credentials to be rejected")
+	}
+}
@@ -0,0 +1,162 @@
+package cms
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	authutil "ocp/sample/planets/internal/util/auth"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Errors an *APIError matches with errors.Is, by status code
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:      ErrBadRequest,
+	http.StatusForbidden:       ErrForbidden,
+	http.StatusNotFound:        ErrNotFound,
+	http.StatusConflict:        ErrConflict,
+	http.StatusTooManyRequests: ErrTooManyRequests,
+}
+
+// Response headers that may carry the id OCP gave the request, in the order they are checked
+var requestIdHeaders = []string{"X-Request-Id", "X-Correlation-Id", "Request-Id"}
+
+// Error response fields that may carry the CMS error code, message and request id, in the order they are checked
+var (
+	errorCodeKeys    = []string{"code", "errorCode", "error_code", "error"}
+	errorMessageKeys = []string{"message", "error_description", "detail", "details"}
+	requestIdKeys    = []string{"request_id", "requestId", "traceId"}
+)
+
+// An error caused by OCP refusing to issue an access token
+type AuthError = authutil.AuthError
+
+// An error response from CMS. Code, Message and RequestId are read from the response when CMS provides them.
+// Use errors.As to inspect it, or errors.Is with ErrNotFound, ErrConflict, ErrTooManyRequests and so on.
+type APIError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Code       string
+	Message    string
+	RequestId  string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s failed (HTTP status code: %d)", e.Method, e.Url, e.StatusCode)
+
+	if len(e.Code) > 0 {
+		fmt.Fprintf(&b, " %s", e.Code)
+	}
+
+	if len(e.Message) > 0 {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+
+	if len(e.RequestId) > 0 {
+		fmt.Fprintf(&b, " (request id: %s)", e.RequestId)
+	}
+
+	return b.String()
+}
+
+// Matches the sentinel error for the status code, e.g. ErrNotFound for a 404.
+func (e *APIError) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// Builds the error for a CMS error response.
+func newAPIError(method string, url string, statusCode int, header http.Header, body string) *APIError {
+	e := &APIError{Method: method, Url: url, StatusCode: statusCode, Body: body}
+
+	if gjson.Valid(body) {
+		e.Code = firstString(body, errorCodeKeys)
+		e.Message = firstString(body, errorMessageKeys)
+		e.RequestId = firstString(body, requestIdKeys)
+	}
+
+	for _, name := range requestIdHeaders {
+		if id := header.Get(name); len(e.RequestId) == 0 && len(id) > 0 {
+			e.RequestId = id
+		}
+	}
+
+	return e
+}
+
+// The first of the keys holding a string in a JSON object.
+func firstString(body string, keys []string) string {
+	for _, key := range keys {
+		if value := gjson.Get(body, key); value.Type == gjson.String && len(value.Str) > 0 {
+			return value.Str
+		}
+	}
+
+	return ""
+}
+
+// An error reporting that no instance has the id or name being looked up
+type NotFoundError struct {
+	SystemTypeName string
+	Id             string
+	Name           string
+	Err            error
+}
+
+func (e *NotFoundError) Error() string {
+	var apiErr *APIError
+
+	if len(e.Name) > 0 {
+		return fmt.Sprintf("No instance of type %s found with name %s", e.SystemTypeName, e.Name)
+	}
+
+	if errors.As(e.Err, &apiErr) && len(apiErr.RequestId) > 0 {
+		return fmt.Sprintf("No instance of type %s found with id %s (request id: %s)", e.SystemTypeName, e.Id, apiErr.RequestId)
+	}
+
+	return fmt.Sprintf("No instance of type %s found with id %s", e.SystemTypeName, e.Id)
+}
+
+// Unwraps to the CMS error response, if there was one.
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// Matches ErrNotFound, also when the name lookup found nothing without CMS returning an error.
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// An error reporting that more than one instance has the name being looked up
+type AmbiguousNameError struct {
+	SystemTypeName string
+	Name           string
+	Ids            []string
+}
+
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("%d instances of type %s are named %s (ids: %s), get one by id instead", len(e.Ids), e.SystemTypeName, e.Name, strings.Join(e.Ids, ", "))
+}
+
+// The HTTP status code of the CMS error response behind an error, or zero when CMS didn't answer with one.
+func StatusCode(err error) int {
+	var apiErr *APIError
+
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	return 0
+}
@@ -0,0 +1,124 @@
+package cms
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// A transport answering every request with the response built by the function
+type fakeTransport func(req *http.Request) *http.Response
+
+func (f fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// A JSON response with the given status, body and headers given as name, value pairs.
+func jsonResponse(statusCode int, body string, header ...string) *http.Response {
+	resp := &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+
+	for i := 0; i+1 < len(header); i += 2 {
+		resp.Header.Set(header[i], header[i+1])
+	}
+
+	return resp
+}
+
+// Creates a client sending every request through the fake transport with a static token.
+func newTestClient(t *testing.T, transport fakeTransport) *Client {
+	client, err := NewClient(Options{
+		BaseUrl:   "https://cms.example.com",
+		Transport: transport,
+		Tokens:    StaticTokenSource("test-token"),
+		Logger:    DiscardLogger,
+	})
+
+	if err != nil {
+		t.Fatalf("Unable to create the client: %s", err)
+	}
+
+	return client
+}
+
+func TestAPIErrorAfterRetriesRunOut(t *testing.T) {
+	attempts := 0
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		attempts++
+		return jsonResponse(http.StatusTooManyRequests, `{"code":"RATE_LIMITED","message":"Slow down"}`, "Retry-After", "0", "X-Request-Id", "req-1")
+	})
+
+	_, err := client.Get(context.Background(), "object", "un_planet", "1")
+
+	var apiErr *APIError
+
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError once retries ran out, got %v", err)
+	}
+
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("Expected the error to match ErrTooManyRequests, got %v", err)
+	}
+
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Code != "RATE_LIMITED" || apiErr.Message != "Slow down" || apiErr.RequestId != "req-1" {
+		t.Errorf("Unexpected error details: %+v", apiErr)
+	}
+
+	if attempts < 2 {
+		t.Errorf("Expected the GET to be retried, sent %d times", attempts)
+	}
+}
+
+func TestAPIErrorMatchesStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		body       string
+		sentinel   error
+	}{
+		{http.StatusBadRequest, `{"error":"BAD_REQUEST","error_description":"Invalid name"}`, ErrBadRequest},
+		{http.StatusForbidden, `not json`, ErrForbidden},
+		{http.StatusConflict, `{"code":"CONFLICT","message":"Name taken","requestId":"req-2"}`, ErrConflict},
+	}
+
+	for _, test := range tests {
+		test := test
+		client := newTestClient(t, func(req *http.Request) *http.Response {
+			return jsonResponse(test.statusCode, test.body)
+		})
+
+		_, err := client.Create(context.Background(), "object", "un_planet", "Mars", nil)
+
+		if !errors.Is(err, test.sentinel) {
+			t.Errorf("Expected HTTP %d to match %v, got %v", test.statusCode, test.sentinel, err)
+		}
+
+		if StatusCode(err) != test.statusCode {
+			t.Errorf("Expected status code %d, got %d", test.statusCode, StatusCode(err))
+		}
+	}
+}
+
+func TestNotFoundWrapsAPIError(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		return jsonResponse(http.StatusNotFound, `{"code":"NOT_FOUND","message":"No such instance"}`, "X-Request-Id", "req-3")
+	})
+
+	_, err := client.Get(context.Background(), "object", "un_planet", "1")
+
+	var notFound *NotFoundError
+	var apiErr *APIError
+
+	if !errors.As(err, &notFound) || !errors.As(err, &apiErr) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected a *NotFoundError wrapping an *APIError, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "req-3") {
+		t.Errorf("Expected the request id in the error, got %q", err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package cms
+
+// HAL link relations used by CMS
+const (
+	LinkSelf   = "self"
+	LinkNext   = "next"
+	LinkPrev   = "prev"
+	LinkDelete = "urn:eim:linkrel:delete"
+)
+
+// A HAL link to a related resource
+type Link struct {
+	Href string `json:"href"`
+}
+
+// HAL links keyed by their relation, e.g. self, next or urn:eim:linkrel:delete
+type Links map[string]Link
+
+// The href of the link with the given relation, or empty when there is none.
+func (l Links) Href(rel string) string {
+	return l[rel].Href
+}
+
+// A page of an instance collection as returned by the CMS list endpoint
+type Page struct {
+	Embedded struct {
+		Collection []Instance `json:"collection"`
+	} `json:"_embedded"`
+	Links Links     `json:"_links,omitempty"`
+	Page  *PageInfo `json:"page,omitempty"`
+}
+
+// Where a page sits in its collection. Numbers start at 1.
+type PageInfo struct {
+	Number        int `json:"number"`
+	Size          int `json:"size"`
+	TotalElements int `json:"totalElements"`
+	TotalPages    int `json:"totalPages"`
+}
+
+// The instances on the page
+func (p Page) Instances() []Instance {
+	return p.Embedded.Collection
+}
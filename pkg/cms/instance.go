@@ -0,0 +1,228 @@
+package cms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	logutil "ocp/sample/planets/internal/util/log"
+)
+
+// A CMS instance. Raw holds the instance exactly as CMS returned it, including any fields not mapped here.
+type Instance struct {
+	Id         string                 `json:"id,omitempty"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type,omitempty"`
+	Category   string                 `json:"category,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	CreateTime string                 `json:"create_time,omitempty"`
+	UpdateTime string                 `json:"update_time,omitempty"`
+	CreatedBy  string                 `json:"created_by,omitempty"`
+	UpdatedBy  string                 `json:"updated_by,omitempty"`
+	Links      Links                  `json:"_links,omitempty"`
+	Raw        json.RawMessage        `json:"-"`
+}
+
+// A partial update of an instance. Only the name, when set, and the given properties are changed.
+type InstancePatch struct {
+	Name       string                 `json:"name,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// What is sent to create or replace an instance
+type instanceBody struct {
+	Name       string                 `json:"name"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Decodes an instance and keeps the JSON it was decoded from in Raw.
+func (i *Instance) UnmarshalJSON(data []byte) (err error) {
+	type fields Instance
+
+	if err = json.Unmarshal(data, (*fields)(i)); err == nil {
+		i.Raw = append(json.RawMessage(nil), data...)
+	}
+
+	return
+}
+
+// Returns the /instances URL for a given category and type.
+func (c *Client) InstancesUrl(category string, systemTypeName string) string {
+	return fmt.Sprintf("%s/instances/%s/%s", c.cmsHost, category, systemTypeName)
+}
+
+// Gets a single instance by id. A missing instance is reported as a *NotFoundError.
+func (c *Client) Get(ctx context.Context, category string, systemTypeName string, id string) (instance Instance, err error) {
+	var respBody string
+
+	_, respBody, err = c.send(ctx, http.MethodGet, c.instanceUrl(category, systemTypeName, id), "")
+
+	if StatusCode(err) == http.StatusNotFound {
+		err = &NotFoundError{SystemTypeName: systemTypeName, Id: id, Err: err}
+		c.Logger.LogError(err)
+	}
+
+	if err == nil {
+		instance, err = c.decodeInstance(respBody)
+	}
+
+	return
+}
+
+// Gets the single instance with the given name. Names aren't unique in CMS, so more than one match
+// is reported as an *AmbiguousNameError listing their ids, and none as a *NotFoundError.
+// The name is filtered on server-side, and checked again here in case the filter isn't applied.
+func (c *Client) FindByName(ctx context.Context, category string, systemTypeName string, name string) (instance Instance, err error) {
+	var matches []Instance
+
+	listOpts := ListOptions{Filters: []Filter{{Field: "name", Operator: filterOperators["="], Value: name}}}
+	pager := c.Instances(ctx, category, systemTypeName, listOpts)
+
+	for pager.Next() {
+		if pager.Instance().Name == name {
+			matches = append(matches, pager.Instance())
+		}
+	}
+
+	err = pager.Err()
+
+	if err == nil {
+		switch len(matches) {
+		case 0:
+			err = &NotFoundError{SystemTypeName: systemTypeName, Name: name}
+		case 1:
+			instance = matches[0]
+		default:
+			ambiguous := &AmbiguousNameError{SystemTypeName: systemTypeName, Name: name}
+
+			for _, match := range matches {
+				ambiguous.Ids = append(ambiguous.Ids, match.Id)
+			}
+
+			err = ambiguous
+		}
+
+		if err != nil {
+			c.Logger.LogError(err)
+		}
+	}
+
+	return
+}
+
+// Creates an instance from its name and properties and returns it as stored by CMS.
+// Nothing is returned in dry-run mode.
+func (c *Client) Create(ctx context.Context, category string, systemTypeName string, name string, properties map[string]interface{}) (instance Instance, err error) {
+	instance, _, err = c.CreateWithStatus(ctx, category, systemTypeName, name, properties)
+
+	return
+}
+
+// Creates an instance like Create, also returning the HTTP status code CMS answered with.
+// The status is DryRunStatusCode in dry-run mode and 0 when no response was received.
+func (c *Client) CreateWithStatus(ctx context.Context, category string, systemTypeName string, name string, properties map[string]interface{}) (instance Instance, statusCode int, err error) {
+	c.Logger.Log(logutil.INFO_LEVEL, "Creating instance", "type", systemTypeName, "name", name)
+
+	return c.write(ctx, http.MethodPost, c.InstancesUrl(category, systemTypeName), instanceBody{Name: name, Properties: properties})
+}
+
+// Replaces the name and every property of an instance and returns it as stored by CMS.
+// Nothing is returned in dry-run mode.
+func (c *Client) Update(ctx context.Context, category string, systemTypeName string, id string, name string, properties map[string]interface{}) (instance Instance, err error) {
+	instance, _, err = c.UpdateWithStatus(ctx, category, systemTypeName, id, name, properties)
+
+	return
+}
+
+// Replaces an instance like Update, also returning the HTTP status code CMS answered with.
+// The status is DryRunStatusCode in dry-run mode and 0 when no response was received.
+func (c *Client) UpdateWithStatus(ctx context.Context, category string, systemTypeName string, id string, name string, properties map[string]interface{}) (instance Instance, statusCode int, err error) {
+	c.Logger.Log(logutil.INFO_LEVEL, "Updating instance", "type", systemTypeName, "id", id, "name", name)
+
+	return c.write(ctx, http.MethodPut, c.instanceUrl(category, systemTypeName, id), instanceBody{Name: name, Properties: properties})
+}
+
+// Changes only the name, when set, and the given properties of an instance, and returns it as stored by CMS.
+// Nothing is returned in dry-run mode.
+func (c *Client) Patch(ctx context.Context, category string, systemTypeName string, id string, patch InstancePatch) (instance Instance, err error) {
+	c.Logger.Log(logutil.INFO_LEVEL, "Patching instance", "type", systemTypeName, "id", id)
+
+	instance, _, err = c.write(ctx, http.MethodPatch, c.instanceUrl(category, systemTypeName, id), patch)
+
+	return
+}
+
+// Deletes an instance by id.
+func (c *Client) Delete(ctx context.Context, category string, systemTypeName string, id string) (err error) {
+	_, err = c.DeleteWithStatus(ctx, category, systemTypeName, id)
+
+	return
+}
+
+// Deletes an instance like Delete, returning the HTTP status code CMS answered with.
+// The status is DryRunStatusCode in dry-run mode and 0 when no response was received.
+func (c *Client) DeleteWithStatus(ctx context.Context, category string, systemTypeName string, id string) (statusCode int, err error) {
+	c.Logger.Log(logutil.INFO_LEVEL, "Deleting instance", "type", systemTypeName, "id", id)
+
+	statusCode, _, err = c.send(ctx, http.MethodDelete, c.instanceUrl(category, systemTypeName, id), "")
+
+	return
+}
+
+// Deletes an instance returned by CMS, following its delete link when it has one.
+func (c *Client) DeleteInstance(ctx context.Context, instance Instance) (err error) {
+	_, err = c.DeleteInstanceWithStatus(ctx, instance)
+
+	return
+}
+
+// Deletes an instance like DeleteInstance, returning the HTTP status code CMS answered with.
+// The status is DryRunStatusCode in dry-run mode and 0 when no response was received.
+func (c *Client) DeleteInstanceWithStatus(ctx context.Context, instance Instance) (statusCode int, err error) {
+	deleteUrl := instance.Links.Href(LinkDelete)
+
+	if len(deleteUrl) == 0 {
+		deleteUrl = c.instanceUrl(instance.Category, instance.Type, instance.Id)
+	}
+
+	c.Logger.Log(logutil.INFO_LEVEL, "Deleting instance", "type", instance.Type, "id", instance.Id, "name", instance.Name)
+
+	statusCode, _, err = c.send(ctx, http.MethodDelete, deleteUrl, "")
+
+	return
+}
+
+func (c *Client) instanceUrl(category string, systemTypeName string, id string) string {
+	return fmt.Sprintf("%s/%s", c.InstancesUrl(category, systemTypeName), id)
+}
+
+// Sends a create, update or patch body and decodes the instance CMS returns along with the response status.
+func (c *Client) write(ctx context.Context, method string, url string, body interface{}) (instance Instance, statusCode int, err error) {
+	var jsonBody []byte
+	var respBody string
+
+	jsonBody, err = json.Marshal(body)
+
+	if err == nil {
+		statusCode, respBody, err = c.send(ctx, method, url, string(jsonBody))
+	} else {
+		c.Logger.LogError(err)
+	}
+
+	if err == nil && len(respBody) > 0 {
+		instance, err = c.decodeInstance(respBody)
+	}
+
+	return
+}
+
+func (c *Client) decodeInstance(raw string) (instance Instance, err error) {
+	err = json.Unmarshal([]byte(raw), &instance)
+
+	if err != nil {
+		err = fmt.Errorf("Unable to read the CMS instance: %s", err)
+		c.Logger.LogError(err)
+	}
+
+	return
+}
@@ -0,0 +1,78 @@
+package cms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Keeps every message logged, prefixed with its level
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Log(level LogLevel, message string, keyvals ...any) {
+	l.lines = append(l.lines, fmt.Sprintf("%s %s %v", level, message, keyvals))
+}
+
+func (l *recordingLogger) LogError(err error, keyvals ...any) {
+	l.Log(LevelError, err.Error(), keyvals...)
+}
+
+func TestCustomLoggerSeesLevelsAndRedactedValues(t *testing.T) {
+	logger := &recordingLogger{}
+	client, err := NewClient(Options{
+		BaseUrl: "https://cms.example.com",
+		Transport: fakeTransport(func(req *http.Request) *http.Response {
+			return jsonResponse(http.StatusBadRequest, `{"message":"bad","properties":{"code":"1234"}}`)
+		}),
+		Tokens:      StaticTokenSource("test-token"),
+		Logger:      logger,
+		RedactPaths: []string{"properties.code"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.Create(context.Background(), "object", "un_planet", "Mars", nil)
+
+	output := strings.Join(logger.lines, "\n")
+
+	if !strings.Contains(output, "INFO Creating instance") || !strings.Contains(output, "ERROR ") {
+		t.Errorf("Expected INFO and ERROR messages, got:\n%s", output)
+	}
+
+	if strings.Contains(output, "1234") {
+		t.Errorf("Expected properties.code to be redacted, got:\n%s", output)
+	}
+}
+
+func TestTextLogger(t *testing.T) {
+	var out bytes.Buffer
+
+	logger := NewTextLogger(&out)
+	logger.SetLevel(LevelWarn)
+	logger.Log(LevelInfo, "skipped")
+	logger.LogError(errors.New("failed"), "id", "1")
+
+	if err := logger.SetFormat(LogFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Log(LevelWarn, "as json")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	if len(lines) != 2 || !strings.Contains(lines[0], "ERROR failed id=1") || !strings.Contains(lines[1], `"msg":"as json"`) {
+		t.Errorf("Unexpected log output:\n%s", out.String())
+	}
+
+	if !strings.Contains(lines[0], "logger_test.go") {
+		t.Errorf("Expected the caller to be reported, got %s", lines[0])
+	}
+}
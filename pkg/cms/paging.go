@@ -0,0 +1,190 @@
+package cms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DefaultPageSize = 100
+
+	pageQueryParam     = "page"
+	pageSizeQueryParam = "items-per-page"
+)
+
+// Controls how collections are paged through when listing instances.
+// A MaxItems value of zero means every available instance is fetched.
+type PageOptions struct {
+	PageSize int
+	MaxItems int
+}
+
+// Walks every page of a CMS instance collection, following the _links.next
+// link (or the page metadata when no link is present) until the collection is
+// exhausted or the configured maximum number of items has been reached.
+type InstancePager struct {
+	client  *Client
+	ctx     context.Context
+	opts    PageOptions
+	nextUrl string
+	page    []Instance
+	index   int
+	fetched int
+	current Instance
+	err     error
+}
+
+// Creates a pager over the instances of a given category and type, narrowed by the list options,
+// using the client's page options. Pages are fetched as the pager advances:
+//
+//	pager := client.Instances(ctx, category, systemTypeName, cms.ListOptions{})
+//	for pager.Next() {
+//		instance := pager.Instance()
+//	}
+//	err := pager.Err()
+func (c *Client) Instances(ctx context.Context, category string, systemTypeName string, listOpts ListOptions) *InstancePager {
+	return c.NewInstancePager(ctx, category, systemTypeName, c.pageOptions, listOpts)
+}
+
+// Creates a pager over the instances of a given category and type, narrowed by the list options.
+func (c *Client) NewInstancePager(ctx context.Context, category string, systemTypeName string, opts PageOptions, listOpts ListOptions) (pager *InstancePager) {
+	var instancesUrl string
+	var err error
+
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+
+	instancesUrl, err = withListQuery(c.InstancesUrl(category, systemTypeName), listOpts)
+
+	if err == nil {
+		instancesUrl, err = withPageQuery(instancesUrl, 1, opts.PageSize)
+	}
+
+	if err != nil {
+		c.Logger.LogError(err)
+	}
+
+	return &InstancePager{client: c, ctx: ctx, opts: opts, nextUrl: instancesUrl, err: err}
+}
+
+// Gets every instance of a given category and type matching the list options, up to the client's maximum number of items.
+func (c *Client) List(ctx context.Context, category string, systemTypeName string, listOpts ListOptions) (instances []Instance, err error) {
+	pager := c.Instances(ctx, category, systemTypeName, listOpts)
+
+	for pager.Next() {
+		instances = append(instances, pager.Instance())
+	}
+
+	return instances, pager.Err()
+}
+
+// Advances to the next instance, fetching the next page when required.
+// Returns false once there are no more instances or an error has occurred.
+func (p *InstancePager) Next() bool {
+	if p.err != nil || p.limitReached() {
+		return false
+	}
+
+	for p.index >= len(p.page) {
+		if len(p.nextUrl) == 0 {
+			return false
+		}
+
+		p.fetchPage()
+
+		if p.err != nil {
+			return false
+		}
+	}
+
+	p.current = p.page[p.index]
+	p.index++
+	p.fetched++
+
+	return true
+}
+
+// The instance the pager is currently positioned on.
+func (p *InstancePager) Instance() Instance {
+	return p.current
+}
+
+// The error that stopped the pager, if any.
+func (p *InstancePager) Err() error {
+	return p.err
+}
+
+func (p *InstancePager) limitReached() bool {
+	return p.opts.MaxItems > 0 && p.fetched >= p.opts.MaxItems
+}
+
+// Fetches the page at nextUrl and works out where the following page lives.
+func (p *InstancePager) fetchPage() {
+	var respBody string
+	var page Page
+
+	pageUrl := p.nextUrl
+	p.nextUrl = ""
+	p.page = nil
+	p.index = 0
+
+	_, respBody, p.err = p.client.send(p.ctx, http.MethodGet, pageUrl, "")
+
+	if p.err == nil {
+		if p.err = json.Unmarshal([]byte(respBody), &page); p.err != nil {
+			p.err = fmt.Errorf("Unable to read the page of instances from %s: %s", pageUrl, p.err)
+			p.client.Logger.LogError(p.err)
+			return
+		}
+
+		p.page = page.Instances()
+
+		if len(p.page) > 0 {
+			p.nextUrl, p.err = nextPageUrl(pageUrl, page, p.opts.PageSize)
+		}
+	}
+}
+
+// Works out the URL of the page following the current one.
+func nextPageUrl(currentUrl string, page Page, pageSize int) (nextUrl string, err error) {
+	if next := page.Links.Href(LinkNext); len(next) > 0 {
+		var base *url.URL
+		var ref *url.URL
+
+		base, err = url.Parse(currentUrl)
+
+		if err == nil {
+			ref, err = url.Parse(next)
+		}
+
+		if err == nil {
+			nextUrl = base.ResolveReference(ref).String()
+		}
+	} else if page.Page != nil && page.Page.Number < page.Page.TotalPages {
+		nextUrl, err = withPageQuery(currentUrl, page.Page.Number+1, pageSize)
+	}
+
+	return
+}
+
+// Sets the page number and page size query parameters on a URL.
+func withPageQuery(rawUrl string, page int, pageSize int) (pageUrl string, err error) {
+	var parsedUrl *url.URL
+
+	parsedUrl, err = url.Parse(rawUrl)
+
+	if err == nil {
+		query := parsedUrl.Query()
+		query.Set(pageQueryParam, strconv.Itoa(page))
+		query.Set(pageSizeQueryParam, strconv.Itoa(pageSize))
+		parsedUrl.RawQuery = query.Encode()
+		pageUrl = parsedUrl.String()
+	}
+
+	return
+}
@@ -0,0 +1,95 @@
+package cms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Serves a collection of the given size page by page, with next links when links is set and page metadata otherwise.
+func pagedCollection(size int, links bool, requests *int) fakeTransport {
+	return func(req *http.Request) *http.Response {
+		*requests++
+
+		query := req.URL.Query()
+		number, _ := strconv.Atoi(query.Get("page"))
+		pageSize, _ := strconv.Atoi(query.Get("items-per-page"))
+		totalPages := (size + pageSize - 1) / pageSize
+
+		var instances []string
+
+		for i := (number - 1) * pageSize; i < size && i < number*pageSize; i++ {
+			instances = append(instances, fmt.Sprintf(`{"id":"%d","name":"Planet %d"}`, i, i))
+		}
+
+		body := fmt.Sprintf(`{"_embedded":{"collection":[%s]}`, strings.Join(instances, ","))
+
+		if links && number < totalPages {
+			body += fmt.Sprintf(`,"_links":{"next":{"href":"?page=%d&items-per-page=%d"}}`, number+1, pageSize)
+		} else if !links {
+			body += fmt.Sprintf(`,"page":{"number":%d,"size":%d,"totalElements":%d,"totalPages":%d}`, number, pageSize, size, totalPages)
+		}
+
+		return jsonResponse(http.StatusOK, body+"}")
+	}
+}
+
+func TestPagerFollowsEveryPage(t *testing.T) {
+	for _, links := range []bool{true, false} {
+		requests := 0
+		client := newTestClient(t, pagedCollection(7, links, &requests))
+		pager := client.NewInstancePager(context.Background(), "object", "un_planet", PageOptions{PageSize: 3}, ListOptions{})
+		count := 0
+
+		for pager.Next() {
+			if want := fmt.Sprintf("Planet %d", count); pager.Instance().Name != want {
+				t.Errorf("Expected %s, got %s", want, pager.Instance().Name)
+			}
+
+			count++
+		}
+
+		if pager.Err() != nil || count != 7 || requests != 3 {
+			t.Errorf("Expected 7 instances from 3 pages (links: %t), got %d from %d request(s), %v", links, count, requests, pager.Err())
+		}
+	}
+}
+
+func TestPagerStopsAtMaxItems(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedCollection(10, true, &requests))
+	pager := client.NewInstancePager(context.Background(), "object", "un_planet", PageOptions{PageSize: 2, MaxItems: 3}, ListOptions{})
+	count := 0
+
+	for pager.Next() {
+		count++
+	}
+
+	if count != 3 || requests != 2 {
+		t.Errorf("Expected 3 instances from 2 pages, got %d from %d request(s)", count, requests)
+	}
+}
+
+func TestPagerDecodesTypedInstances(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		return jsonResponse(http.StatusOK, `{"_embedded":{"collection":[{"id":"1","name":"Mars","type":"un_planet",
+			"properties":{"diameter":6779},"create_time":"2024-01-02T03:04:05Z",
+			"_links":{"self":{"href":"https://cms.example.com/cms/instances/object/un_planet/1"},
+			"urn:eim:linkrel:delete":{"href":"https://cms.example.com/cms/instances/object/un_planet/1"}}}]}}`)
+	})
+
+	instances, err := client.List(context.Background(), "object", "un_planet", ListOptions{})
+
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("Expected one instance, got %d, %v", len(instances), err)
+	}
+
+	mars := instances[0]
+
+	if mars.Properties["diameter"] != float64(6779) || mars.CreateTime != "2024-01-02T03:04:05Z" || len(mars.Links.Href(LinkDelete)) == 0 || !strings.Contains(string(mars.Raw), `"diameter":6779`) {
+		t.Errorf("Unexpected instance: %+v", mars)
+	}
+}
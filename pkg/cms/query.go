@@ -0,0 +1,190 @@
+package cms
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	filterQueryParam     = "filter"
+	sortQueryParam       = "sortby"
+	projectionQueryParam = "fields"
+
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// Instance attributes that aren't properties. Any other name in a filter, sort or projection refers to a property.
+var instanceAttributes = map[string]bool{
+	"id":          true,
+	"name":        true,
+	"type":        true,
+	"create_time": true,
+	"update_time": true,
+	"created_by":  true,
+	"updated_by":  true,
+}
+
+// Filter operators as typed by the user and the CMS operator each one maps to
+var filterOperators = map[string]string{
+	"=":        "eq",
+	"==":       "eq",
+	"!=":       "ne",
+	">":        "gt",
+	">=":       "ge",
+	"<":        "lt",
+	"<=":       "le",
+	"contains": "contains",
+}
+
+var filterPattern = regexp.MustCompile(`^\s*([\w.]+)\s*(==|!=|>=|<=|=|>|<|\s+contains\s+)\s*(.*?)\s*$`)
+
+// Narrows, orders and trims the instances returned by the CMS list endpoint.
+// Every option is passed to CMS as a query parameter so the work is done server-side.
+type ListOptions struct {
+	Filters    []Filter
+	Sort       []Sort
+	Properties []string
+}
+
+// A comparison between an instance attribute or property and a value, e.g. diameter > 10000.
+// Quoted values are always sent as strings, so name = '2001' doesn't become a number.
+type Filter struct {
+	Field    string
+	Operator string
+	Value    string
+	Quoted   bool
+}
+
+// An attribute or property to sort by and the direction, asc or desc
+type Sort struct {
+	Field     string
+	Direction string
+}
+
+// Parses a filter expression such as "diameter > 10000", "name = Earth" or "name contains ar".
+// Values may be quoted, e.g. name = 'New Earth'.
+func ParseFilter(expression string) (filter Filter, err error) {
+	match := filterPattern.FindStringSubmatch(expression)
+
+	if match == nil || len(match[3]) == 0 {
+		err = fmt.Errorf("Invalid filter %q, expected <field> <operator> <value> where the operator is one of =, !=, >, >=, <, <= or contains", expression)
+		return
+	}
+
+	value := match[3]
+	quoted := len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0]
+
+	if quoted {
+		value = value[1 : len(value)-1]
+	}
+
+	filter = Filter{
+		Field:    fieldPath(match[1]),
+		Operator: filterOperators[strings.TrimSpace(match[2])],
+		Value:    value,
+		Quoted:   quoted,
+	}
+
+	return
+}
+
+// Parses a sort such as "name", "diameter:desc" or "-diameter".
+func ParseSort(expression string) (sort Sort, err error) {
+	field, direction, found := strings.Cut(strings.TrimSpace(expression), ":")
+
+	if !found {
+		direction = SortAscending
+
+		if strings.HasPrefix(field, "-") {
+			field, direction = strings.TrimPrefix(field, "-"), SortDescending
+		}
+	}
+
+	direction = strings.ToLower(direction)
+
+	if len(field) == 0 || (direction != SortAscending && direction != SortDescending) {
+		err = fmt.Errorf("Invalid sort %q, expected <field>, <field>:asc, <field>:desc or -<field>", expression)
+		return
+	}
+
+	sort = Sort{Field: fieldPath(field), Direction: direction}
+
+	return
+}
+
+// The filter in CMS syntax, e.g. properties.diameter gt 10000 or name eq 'Earth'. Numbers and booleans are
+// sent bare unless the value was quoted; instance attributes such as name are strings, so they are always quoted.
+func (f Filter) String() string {
+	value := f.Value
+
+	if f.Quoted || instanceAttributes[f.Field] || f.Operator == filterOperators["contains"] || !isLiteral(value) {
+		value = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+
+	return fmt.Sprintf("%s %s %s", f.Field, f.Operator, value)
+}
+
+// Reports whether a value is a number or boolean literal.
+func isLiteral(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+
+	return err == nil || value == "true" || value == "false"
+}
+
+// The sort in CMS syntax, e.g. properties.diameter desc
+func (s Sort) String() string {
+	return fmt.Sprintf("%s %s", s.Field, s.Direction)
+}
+
+// Adds the filter, sort and projection query parameters to a list URL.
+func withListQuery(rawUrl string, opts ListOptions) (listUrl string, err error) {
+	var parsedUrl *url.URL
+
+	parsedUrl, err = url.Parse(rawUrl)
+
+	if err == nil {
+		query := parsedUrl.Query()
+
+		if len(opts.Filters) > 0 {
+			var filters []string
+			for _, filter := range opts.Filters {
+				filters = append(filters, filter.String())
+			}
+			query.Set(filterQueryParam, strings.Join(filters, " and "))
+		}
+
+		if len(opts.Sort) > 0 {
+			var sorts []string
+			for _, sort := range opts.Sort {
+				sorts = append(sorts, sort.String())
+			}
+			query.Set(sortQueryParam, strings.Join(sorts, ","))
+		}
+
+		if len(opts.Properties) > 0 {
+			var properties []string
+			for _, property := range opts.Properties {
+				properties = append(properties, fieldPath(property))
+			}
+			query.Set(projectionQueryParam, strings.Join(properties, ","))
+		}
+
+		parsedUrl.RawQuery = query.Encode()
+		listUrl = parsedUrl.String()
+	}
+
+	return
+}
+
+// Bare names that aren't instance attributes refer to properties, so diameter becomes properties.diameter.
+func fieldPath(field string) string {
+	if instanceAttributes[field] || strings.Contains(field, ".") {
+		return field
+	}
+
+	return "properties." + field
+}
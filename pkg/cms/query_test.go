@@ -0,0 +1,57 @@
+package cms
+
+import "testing"
+
+func TestFilterQuoting(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"diameter > 10000", "properties.diameter gt 10000"},
+		{"has_rings = true", "properties.has_rings eq true"},
+		{"code = '2001'", "properties.code eq '2001'"},
+		{`code = "true"`, "properties.code eq 'true'"},
+		{"name = 2001", "name eq '2001'"},
+		{"name = 'New Earth'", "name eq 'New Earth'"},
+		{"name contains 20", "name contains '20'"},
+		{"moon != O'Brien", "properties.moon ne 'O''Brien'"},
+		{"name = 'Earth", "name eq '''Earth'"},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expression)
+
+		if err != nil {
+			t.Errorf("Unable to parse %q: %s", test.expression, err)
+		} else if got := filter.String(); got != test.want {
+			t.Errorf("Expected %q to become %q, got %q", test.expression, test.want, got)
+		}
+	}
+}
+
+func TestInvalidFilter(t *testing.T) {
+	for _, expression := range []string{"", "diameter", "diameter >", "diameter ~ 3"} {
+		if _, err := ParseFilter(expression); err == nil {
+			t.Errorf("Expected %q to be rejected", expression)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := map[string]string{
+		"name":          "name asc",
+		"diameter:desc": "properties.diameter desc",
+		"-diameter":     "properties.diameter desc",
+		"name:ASC":      "name asc",
+	}
+
+	for expression, want := range tests {
+		if sort, err := ParseSort(expression); err != nil || sort.String() != want {
+			t.Errorf("Expected %q to become %q, got %q, %v", expression, want, sort.String(), err)
+		}
+	}
+
+	if _, err := ParseSort("name:up"); err == nil {
+		t.Error("Expected an unknown direction to be rejected")
+	}
+}